@@ -1,11 +1,41 @@
 package main
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
 
+	"github.com/keptn-sandbox/locust-service/pkg/baseline"
+	"github.com/keptn-sandbox/locust-service/pkg/history"
+	"github.com/keptn-sandbox/locust-service/pkg/junit"
+	"github.com/keptn-sandbox/locust-service/pkg/kubejob"
+	"github.com/keptn-sandbox/locust-service/pkg/results"
+	"github.com/keptn-sandbox/locust-service/pkg/runner"
+	"github.com/keptn-sandbox/locust-service/pkg/schedule"
+	"github.com/keptn-sandbox/locust-service/pkg/shape"
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/keptn-sandbox/locust-service/pkg/targetlock"
+	"github.com/keptn-sandbox/locust-service/pkg/thresholds"
+	"github.com/keptn-sandbox/locust-service/pkg/virtualenv"
+	"github.com/keptn-sandbox/locust-service/pkg/workerpool"
+	"github.com/keptn-sandbox/locust-service/pkg/workspace"
+	"github.com/keptn/go-utils/pkg/api/models"
+	api "github.com/keptn/go-utils/pkg/api/utils"
 	"github.com/keptn/go-utils/pkg/lib/v0_2_0/fake"
+	"github.com/stretchr/testify/assert"
 
 	keptn "github.com/keptn/go-utils/pkg/lib/keptn"
 	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
@@ -38,3 +68,1234 @@ func initializeTestObjects(eventFileName string) (*keptnv2.Keptn, *cloudevents.E
 
 	return myKeptn, incomingEvent, err
 }
+
+func TestParseLocustConf_Thresholds(t *testing.T) {
+	input := []byte(`---
+spec_version: '0.1.0'
+workloads:
+  - teststrategy: performance
+    script: locust/load.py
+    thresholds:
+      - max_failure_ratio: 0.01
+        max_p95: 500
+      - endpoint: /carts
+        max_p99: 1000
+        severity: warning
+`)
+
+	locustConf, err := parseLocustConf(input)
+	assert.NoError(t, err)
+	assert.Len(t, locustConf.Workloads, 1)
+	assert.Len(t, locustConf.Workloads[0].Thresholds, 2)
+	assert.Equal(t, 0.01, *locustConf.Workloads[0].Thresholds[0].MaxFailureRatio)
+	assert.Equal(t, thresholds.SeverityWarning, locustConf.Workloads[0].Thresholds[1].Severity)
+}
+
+func TestParseLocustConf_InvalidThreshold(t *testing.T) {
+	input := []byte(`---
+spec_version: '0.1.0'
+workloads:
+  - teststrategy: performance
+    thresholds:
+      - endpoint: /carts
+`)
+
+	_, err := parseLocustConf(input)
+	assert.Error(t, err)
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	maxP95 := 50.0
+	statistics := &stats.Statistics{
+		Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 10, P95: 60},
+	}
+
+	result, message, violations := evaluateThresholds([]*thresholds.Threshold{{MaxP95: &maxP95}}, statistics)
+	assert.Equal(t, keptnv2.ResultFailed, result)
+	assert.Contains(t, message, "p95 of Aggregated is 60.00 (max 50.00)")
+	assert.Len(t, violations, 1)
+
+	result, _, _ = evaluateThresholds([]*thresholds.Threshold{{MaxP95: &maxP95, Severity: thresholds.SeverityWarning}}, statistics)
+	assert.Equal(t, keptnv2.ResultWarning, result)
+
+	result, _, _ = evaluateThresholds([]*thresholds.Threshold{{MaxP95: &maxP95}}, nil)
+	assert.Equal(t, keptnv2.ResultFailed, result)
+}
+
+func TestCompareWithBaseline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	runHistory, err = history.Open(filepath.Join(dir, "history.db"))
+	assert.NoError(t, err)
+	defer func() {
+		runHistory.Close()
+		runHistory = nil
+	}()
+
+	previous := &stats.Statistics{Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 10, P95: 100}}
+	assert.NoError(t, runHistory.Save(&history.Run{ID: "1", Project: "sockshop", Stage: "dev", Service: "carts", TestStrategy: "performance", Start: time.Now().Add(-time.Hour), Result: "pass", Statistics: previous}))
+	assert.NoError(t, runHistory.Save(&history.Run{ID: "2", Project: "sockshop", Stage: "dev", Service: "carts", TestStrategy: "performance", Start: time.Now().Add(-time.Hour), Result: "fail", Statistics: previous}))
+	assert.NoError(t, runHistory.Save(&history.Run{ID: "3", Project: "sockshop", Stage: "staging", Service: "carts", TestStrategy: "performance", Start: time.Now().Add(-time.Hour), Result: "pass", Statistics: previous}))
+	// scheduled runs are no baseline, although they are more recent
+	scheduled := &stats.Statistics{Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 10, P95: 150}}
+	assert.NoError(t, runHistory.Save(&history.Run{ID: "4", Project: "sockshop", Stage: "dev", Service: "carts", TestStrategy: "performance", Schedule: "sockshop/dev/carts/background", Start: time.Now().Add(-time.Minute), Result: "pass", Statistics: scheduled}))
+
+	failPercent := 20.0
+	current := &stats.Statistics{Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 10, P95: 150}}
+	comparison, err := compareWithBaseline(&baseline.Config{FailPercent: &failPercent}, "sockshop", "dev", "carts", "performance", "", current)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, comparison.BaselineRuns)
+	assert.Equal(t, keptnv2.ResultFailed, resultForSeverity(comparison.Severity))
+}
+
+func TestWorstResult(t *testing.T) {
+	assert.Equal(t, keptnv2.ResultPass, worstResult(keptnv2.ResultPass, keptnv2.ResultPass))
+	assert.Equal(t, keptnv2.ResultWarning, worstResult(keptnv2.ResultWarning, keptnv2.ResultPass))
+	assert.Equal(t, keptnv2.ResultFailed, worstResult(keptnv2.ResultWarning, keptnv2.ResultFailed))
+}
+
+func TestGetWorkers(t *testing.T) {
+	defaultWorkers = 2
+	defer func() { defaultWorkers = 0 }()
+
+	workers := 4
+	assert.Equal(t, 4, getWorkers(&Workload{Workers: &workers}))
+	assert.Equal(t, 2, getWorkers(&Workload{}))
+	assert.Equal(t, 2, getWorkers(nil))
+
+	_, err := parseLocustConf([]byte("workloads:\n  - teststrategy: performance\n    workers: -1\n"))
+	assert.Error(t, err)
+}
+
+func TestExitCode(t *testing.T) {
+	assert.Equal(t, 0, exitCode(nil))
+	assert.Equal(t, -1, exitCode(errors.New("failed")))
+	assert.Equal(t, 3, exitCode(fmt.Errorf("wrapped: %w", &kubejob.JobFailedError{Job: "locust-1", ExitCode: 3})))
+}
+
+func TestQueueTestTriggeredEvent_QueueFull(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+
+	release := make(chan struct{})
+	previousQueue := testQueue
+	testQueue = workerpool.NewPool(1, 0)
+	defer func() {
+		close(release)
+		testQueue.Close()
+		testQueue = previousQueue
+	}()
+	_, err = testQueue.Submit(func() { <-release })
+	assert.NoError(t, err)
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	err = queueTestTriggeredEvent(myKeptn, *incomingEvent, data)
+	assert.Error(t, err)
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	assert.NoError(t, eventSender.AssertSentEventTypes([]string{
+		keptnv2.GetStartedEventType(keptnv2.TestTaskName),
+		keptnv2.GetFinishedEventType(keptnv2.TestTaskName),
+	}))
+	finishedData := &keptnv2.TestFinishedEventData{}
+	assert.NoError(t, eventSender.SentEvents[1].DataAs(finishedData))
+	assert.Equal(t, keptnv2.StatusErrored, finishedData.Status)
+}
+
+func TestQueueTestTriggeredEvent_Queued(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+
+	release := make(chan struct{})
+	previousQueue, previousRunner := testQueue, locustRunner
+	testQueue = workerpool.NewPool(1, 1)
+	locustRunner = &runner.Fake{Files: map[string]string{"locust_stats.csv": testStatsCSV}}
+	defer func() {
+		testQueue, locustRunner = previousQueue, previousRunner
+	}()
+	_, err = testQueue.Submit(func() { <-release })
+	assert.NoError(t, err)
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	// the test is acknowledged before its position in the queue is reported
+	assert.NoError(t, queueTestTriggeredEvent(myKeptn, *incomingEvent, data))
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	assert.NoError(t, eventSender.AssertSentEventTypes([]string{
+		keptnv2.GetStartedEventType(keptnv2.TestTaskName),
+		keptnv2.GetStatusChangedEventType(keptnv2.TestTaskName),
+	}))
+
+	// the worker does not send another test.started event
+	close(release)
+	testQueue.Close()
+	types := []string{}
+	for _, event := range eventSender.SentEvents {
+		types = append(types, event.Type())
+	}
+	assert.Equal(t, keptnv2.GetStartedEventType(keptnv2.TestTaskName), types[0])
+	assert.NotContains(t, types[1:], keptnv2.GetStartedEventType(keptnv2.TestTaskName))
+	assert.Equal(t, keptnv2.GetFinishedEventType(keptnv2.TestTaskName), types[len(types)-1])
+}
+
+// openTestHistory opens a run history for the test
+func openTestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	assert.NoError(t, err)
+	runHistory, err = history.Open(filepath.Join(dir, "history.db"))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		runHistory.Close()
+		runHistory = nil
+		os.RemoveAll(dir)
+	})
+}
+
+func TestQueueTestTriggeredEvent_History(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+	openTestHistory(t)
+	serveResources(t, myKeptn, map[string]string{
+		LocustConfFilename: "workloads:\n  - teststrategy: performance\n    name: browse\n    script: locust/load.py\n  - teststrategy: performance\n    name: checkout\n    script: locust/load.py\n",
+		"locust/load.py":   "from locust import HttpUser",
+	})
+
+	release := make(chan struct{})
+	previousQueue := testQueue
+	testQueue = workerpool.NewPool(1, 1)
+	defer func() {
+		testQueue = previousQueue
+	}()
+	_, err = testQueue.Submit(func() { <-release })
+	assert.NoError(t, err)
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	// the test is recorded while it waits for a worker
+	assert.NoError(t, queueTestTriggeredEvent(myKeptn, *incomingEvent, data))
+	run, err := runHistory.Get(incomingEvent.ID())
+	assert.NoError(t, err)
+	assert.Equal(t, RunStatusQueued, run.Status)
+
+	previousRunner := locustRunner
+	fakeRunner := &runner.Fake{Block: true}
+	locustRunner = fakeRunner
+	defer func() {
+		locustRunner = previousRunner
+	}()
+	close(release)
+
+	// the runs of the workloads are recorded once they are known, each one is running when locust has been started
+	assert.Eventually(t, func() bool {
+		run, err := runHistory.Get(incomingEvent.ID() + "-1")
+		return err == nil && run.Status == RunStatusRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	_, err = runHistory.Get(incomingEvent.ID())
+	assert.Equal(t, history.ErrRunNotFound, err)
+	run, err = runHistory.Get(incomingEvent.ID() + "-2")
+	assert.NoError(t, err)
+	assert.Equal(t, RunStatusQueued, run.Status)
+
+	// every run ID of the history cancels the test
+	assert.True(t, cancelTest(incomingEvent.ID()+"-2"))
+	testQueue.Close()
+
+	run, err = runHistory.Get(incomingEvent.ID() + "-1")
+	assert.NoError(t, err)
+	assert.Equal(t, RunStatusAborted, run.Status)
+	run, err = runHistory.Get(incomingEvent.ID() + "-2")
+	assert.NoError(t, err)
+	assert.Equal(t, RunStatusAborted, run.Status)
+	assert.False(t, cancelTest(incomingEvent.ID()+"-2"))
+	assert.False(t, cancelTest("unknown"))
+}
+
+func TestHandleTestTriggeredEvent_Aborted(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.NoError(t, HandleTestTriggeredEvent(ctx, &runner.Fake{}, myKeptn, *incomingEvent, data))
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	assert.NoError(t, eventSender.AssertSentEventTypes([]string{
+		keptnv2.GetStartedEventType(keptnv2.TestTaskName),
+		keptnv2.GetFinishedEventType(keptnv2.TestTaskName),
+	}))
+	finishedData := &keptnv2.TestFinishedEventData{}
+	assert.NoError(t, eventSender.SentEvents[1].DataAs(finishedData))
+	assert.Equal(t, AbortedMessage, finishedData.Message)
+}
+
+func TestHandleSequenceAbortedEvent(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+
+	ctx, done := runRegistry.Register(context.Background(), incomingEvent.ID(), myKeptn.KeptnContext)
+	defer done()
+
+	assert.NoError(t, HandleSequenceAbortedEvent(myKeptn, *incomingEvent))
+	assert.Equal(t, context.Canceled, ctx.Err())
+}
+
+func TestGetTimeout(t *testing.T) {
+	previousMax := maxTimeout
+	defer func() { maxTimeout = previousMax }()
+
+	maxTimeout = time.Hour
+	assert.Equal(t, 15*time.Minute, getTimeout(&Workload{Timeout: "15m"}, time.Hour))
+	assert.Equal(t, time.Hour, getTimeout(&Workload{Timeout: "3h"}, 0))
+	assert.Equal(t, time.Hour, getTimeout(&Workload{}, 0))
+	assert.Equal(t, time.Hour, getTimeout(nil, 0))
+
+	// without timeout the workload may run for its run time and stop timeout plus the margin
+	assert.Equal(t, time.Hour+timeoutMargin, getTimeout(nil, time.Hour))
+	assert.Equal(t, time.Hour+30*time.Second+timeoutMargin, getTimeout(&Workload{StopTimeout: "30s"}, time.Hour))
+
+	maxTimeout = 0
+	assert.Equal(t, 3*time.Hour, getTimeout(&Workload{Timeout: "3h"}, 0))
+	assert.Equal(t, time.Duration(0), getTimeout(nil, 0))
+
+	_, err := parseLocustConf([]byte("workloads:\n  - teststrategy: performance\n    timeout: soon\n"))
+	assert.Error(t, err)
+
+	// the run time must not exceed the maximum timeout
+	maxTimeout = time.Hour
+	_, err = parseLocustConf([]byte("workloads:\n  - teststrategy: performance\n    run_time: 2h\n"))
+	assert.Error(t, err)
+	_, err = parseLocustConf([]byte("workloads:\n  - teststrategy: performance\n    run_time: 1h\n"))
+	assert.NoError(t, err)
+}
+
+func TestLoadArguments(t *testing.T) {
+	locustConf, err := parseLocustConf([]byte(`---
+spec_version: '0.1.0'
+workloads:
+  - teststrategy: performance
+    users: 50
+    spawn_rate: 2.5
+    run_time: 1h30m
+    stop_timeout: 1m
+    tags: [checkout, cart]
+    exclude_tags: [slow]
+`))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"--users=50", "--spawn-rate=2.5", "--run-time=1h30m", "--stop-timeout=60",
+		"--tags", "checkout", "cart", "--exclude-tags", "slow",
+	}, loadArguments(locustConf.Workloads[0], true))
+
+	assert.Equal(t, []string{"--users=10", "--run-time=2m"}, loadArguments(nil, true))
+	assert.Equal(t, []string{}, loadArguments(&Workload{}, false))
+}
+
+func TestParseLocustConf_InvalidLoadParameters(t *testing.T) {
+	for _, workload := range []string{
+		"users: 0",
+		"spawn_rate: -1",
+		"run_time: 10 minutes",
+		"run_time: 1m30h",
+		"stop_timeout: 5",
+		"tags: ['']",
+		"exclude_tags: ['two words']",
+	} {
+		_, err := parseLocustConf([]byte("workloads:\n  - teststrategy: performance\n    " + workload + "\n"))
+		assert.Error(t, err, workload)
+	}
+}
+
+func TestLoadArguments_LoadProfile(t *testing.T) {
+	previousProfile := loadProfile
+	defer func() { loadProfile = previousProfile }()
+
+	loadProfile = LoadProfile{DefaultUsers: 25, DefaultSpawnRate: 5, DefaultRunTime: "10m"}
+	assert.Equal(t, []string{"--users=25", "--spawn-rate=5", "--run-time=10m"}, loadArguments(nil, true))
+
+	users := 3
+	assert.Equal(t, []string{"--users=3", "--spawn-rate=5", "--run-time=10m"}, loadArguments(&Workload{Users: &users}, true))
+	assert.Equal(t, []string{}, loadArguments(nil, false))
+}
+
+func TestCheckLoadLimits(t *testing.T) {
+	previousProfile, previousMax := loadProfile, maxTimeout
+	defer func() { loadProfile, maxTimeout = previousProfile, previousMax }()
+	maxTimeout = 0
+
+	dir, err := ioutil.TempDir("", "limits")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	confFile := filepath.Join(dir, "locust.conf")
+	assert.NoError(t, ioutil.WriteFile(confFile, []byte("# comment\nusers = 500\nspawn-rate = 10\n"), 0644))
+
+	loadProfile = LoadProfile{DefaultUsers: 10, DefaultRunTime: "2m", MaxUsers: 100, MaxRunTime: time.Hour}
+
+	arguments, err := checkLoadLimits(nil, "")
+	assert.NoError(t, err)
+	assert.Empty(t, arguments)
+
+	users := 200
+	_, err = checkLoadLimits(&Workload{Users: &users}, "")
+	assert.Error(t, err)
+	_, err = checkLoadLimits(&Workload{RunTime: "2h"}, "")
+	assert.Error(t, err)
+
+	// the conf file exceeds the users and does not limit the run time
+	_, err = checkLoadLimits(nil, confFile)
+	assert.Error(t, err)
+	users = 50
+	arguments, err = checkLoadLimits(&Workload{Users: &users}, confFile)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"--run-time=3600s"}, arguments)
+
+	loadProfile = LoadProfile{}
+	arguments, err = checkLoadLimits(&Workload{RunTime: "100h"}, confFile)
+	assert.NoError(t, err)
+	assert.Empty(t, arguments)
+
+	// the run time of the conf file must not exceed the maximum timeout
+	maxTimeout = time.Hour
+	assert.NoError(t, ioutil.WriteFile(confFile, []byte("run-time = 3h\n"), 0644))
+	_, err = checkLoadLimits(nil, confFile)
+	assert.Error(t, err)
+}
+
+func TestParseLocustConf_Shape(t *testing.T) {
+	locustConf, err := parseLocustConf([]byte(`---
+spec_version: '0.1.0'
+workloads:
+  - teststrategy: performance
+    script: locust/load.py
+    shape:
+      profile: step
+      users: 100
+      duration: 10m
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, 100, locustConf.Workloads[0].Shape.PeakUsers())
+	// users and run time are controlled by the shape
+	assert.Equal(t, []string{}, loadArguments(locustConf.Workloads[0], true))
+
+	_, err = parseLocustConf([]byte("workloads:\n  - teststrategy: performance\n    run_time: 5m\n    shape:\n      profile: ramp\n      users: 10\n      duration: 5m\n"))
+	assert.Error(t, err)
+	_, err = parseLocustConf([]byte("workloads:\n  - teststrategy: performance\n    shape:\n      profile: wave\n"))
+	assert.Error(t, err)
+}
+
+func TestWriteShapeLocustfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "shape")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	confFile := filepath.Join(dir, "locust.conf")
+	assert.NoError(t, ioutil.WriteFile(confFile, []byte("locustfile = "+filepath.Join(dir, "basic.py")+"\n"), 0644))
+	config := &shape.Config{Profile: shape.ProfileRamp, Users: 10, Duration: "1m"}
+
+	filename, err := writeShapeLocustfile(config, "", confFile, filepath.Join(dir, shape.Filename))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, shape.Filename), filename)
+	content, err := ioutil.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), filepath.Join(dir, "basic.py"))
+
+	_, err = writeShapeLocustfile(config, "", "", filepath.Join(dir, shape.Filename))
+	assert.Error(t, err)
+}
+
+func TestParseLocustConf_Execution(t *testing.T) {
+	locustConf, err := parseLocustConf([]byte(`---
+spec_version: '0.1.0'
+execution: parallel
+workloads:
+  - teststrategy: performance
+    name: browse
+    script: locust/browse.py
+  - teststrategy: performance
+    name: checkout
+    script: locust/checkout.py
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, ExecutionParallel, locustConf.Execution)
+
+	_, err = parseLocustConf([]byte("execution: random\nworkloads: []\n"))
+	assert.Error(t, err)
+	_, err = parseLocustConf([]byte("workloads:\n  - teststrategy: performance\n    name: browse\n  - teststrategy: performance\n    name: browse\n"))
+	assert.Error(t, err)
+}
+
+func TestNewWorkloadRuns(t *testing.T) {
+	workloads := []*Workload{
+		{TestStrategy: "performance", Name: "browse", Script: "locust/browse.py"},
+		{TestStrategy: "functional", Script: "locust/basic.py"},
+		{TestStrategy: "performance", Script: "locust/checkout.py", Conf: "locust/checkout.conf"},
+	}
+
+	runs := newWorkloadRuns(workloads, "performance", "abc")
+	assert.Len(t, runs, 2)
+	assert.Equal(t, "abc-1", runs[0].id)
+	assert.Equal(t, "browse", runs[0].name)
+	assert.Equal(t, "abc-2", runs[1].id)
+	assert.Equal(t, "locust/checkout.py", runs[1].name)
+	assert.Equal(t, "locust/checkout.conf", runs[1].configFile)
+	assert.Equal(t, "[browse] Locust test aborted", runs[0].describe(AbortedMessage))
+	// unnamed workloads sharing the test strategy are recorded with their display name to keep their baselines apart
+	assert.Equal(t, "browse", runs[0].workloadName())
+	assert.Equal(t, "locust/checkout.py", runs[1].workloadName())
+
+	runs = newWorkloadRuns(workloads, "functional", "abc")
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "abc", runs[0].id)
+	assert.Equal(t, AbortedMessage, runs[0].describe(AbortedMessage))
+	assert.Equal(t, "", runs[0].workloadName())
+
+	// without matching workload the test is skipped
+	runs = newWorkloadRuns(workloads, "unknown", "abc")
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "", runs[0].locustFilename)
+	assert.Equal(t, "", runs[0].configFile)
+}
+
+func TestWorkloadFilename(t *testing.T) {
+	assert.Equal(t, "report.html", workloadFilename(LocustReportFilename, 0))
+	assert.Equal(t, "report-2.html", workloadFilename(LocustReportFilename, 1))
+	assert.Equal(t, "locust-3", workloadFilename(LocustCSVPrefix, 2))
+	assert.Equal(t, []string{"locust-2_stats.csv", "locust-2_failures.csv", "locust-2_stats_history.csv", "report-2.html"}, locustResultFiles(1))
+}
+
+func TestAggregateResults(t *testing.T) {
+	single := &WorkloadResult{Name: "browse", Status: keptnv2.StatusSucceeded, Result: keptnv2.ResultWarning, Message: "Locust test finished with 1 violated threshold(s):\np95 too high"}
+	status, result, message := aggregateResults([]*WorkloadResult{single})
+	assert.Equal(t, keptnv2.StatusSucceeded, status)
+	assert.Equal(t, keptnv2.ResultWarning, result)
+	assert.Equal(t, single.Message, message)
+
+	status, result, message = aggregateResults([]*WorkloadResult{
+		single,
+		{Name: "checkout", Status: keptnv2.StatusSucceeded, Result: keptnv2.ResultPass, Message: "Locust test finished successfully"},
+	})
+	assert.Equal(t, keptnv2.StatusSucceeded, status)
+	assert.Equal(t, keptnv2.ResultWarning, result)
+	assert.Equal(t, "Locust test finished 2 workloads with result warning:\n"+
+		"- browse: warning (Locust test finished with 1 violated threshold(s):)\n"+
+		"- checkout: pass (Locust test finished successfully)", message)
+
+	status, result, _ = aggregateResults([]*WorkloadResult{
+		single,
+		{Name: "checkout", Status: keptnv2.StatusErrored, Result: keptnv2.ResultFailed, Message: "Locust test timed out after 1m0s"},
+	})
+	assert.Equal(t, keptnv2.StatusErrored, status)
+	assert.Equal(t, keptnv2.ResultFailed, result)
+}
+
+func TestParseLocustConf_Readiness(t *testing.T) {
+	locustConf, err := parseLocustConf([]byte(`---
+spec_version: '0.1.0'
+readiness:
+  path: /health
+  status: 204
+  timeout: 5m
+  interval: 10s
+workloads:
+  - teststrategy: performance
+    script: locust/load.py
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "/health", locustConf.Readiness.Path)
+	assert.Equal(t, 5*time.Minute, locustConf.Readiness.TimeoutDuration())
+
+	_, err = parseLocustConf([]byte("readiness:\n  interval: soon\nworkloads: []\n"))
+	assert.Error(t, err)
+}
+
+func TestParseLocustConf_ConcurrencyPolicy(t *testing.T) {
+	locustConf, err := parseLocustConf([]byte(`---
+spec_version: '0.1.0'
+workloads:
+  - teststrategy: performance
+    script: locust/load.py
+    concurrency_policy: reject
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, ConcurrencyReject, locustConf.Workloads[0].ConcurrencyPolicy)
+
+	_, err = parseLocustConf([]byte("workloads:\n  - teststrategy: performance\n    concurrency_policy: sometimes\n"))
+	assert.Error(t, err)
+}
+
+func TestConcurrencyPolicy(t *testing.T) {
+	parallel := &workloadRun{workload: &Workload{ConcurrencyPolicy: ConcurrencyParallel}}
+	reject := &workloadRun{workload: &Workload{ConcurrencyPolicy: ConcurrencyReject}}
+	defaultLocustfile := &workloadRun{}
+
+	assert.Equal(t, ConcurrencyWait, concurrencyPolicy([]*workloadRun{defaultLocustfile}))
+	assert.Equal(t, ConcurrencyParallel, concurrencyPolicy([]*workloadRun{parallel}))
+	assert.Equal(t, ConcurrencyWait, concurrencyPolicy([]*workloadRun{parallel, {workload: &Workload{}}}))
+	assert.Equal(t, ConcurrencyReject, concurrencyPolicy([]*workloadRun{parallel, reject, defaultLocustfile}))
+}
+
+func TestLockTarget(t *testing.T) {
+	myKeptn, _, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	serviceURL, err := url.Parse("http://carts.sockshop-dev:80")
+	assert.NoError(t, err)
+
+	previousTargetLocks := targetLocks
+	targetLocks = targetlock.New()
+	defer func() {
+		targetLocks = previousTargetLocks
+	}()
+
+	key := targetKey(myKeptn, serviceURL)
+	other, _ := targetLocks.TryAcquire(key, "other-context")
+	assert.NotNil(t, other)
+
+	// parallel workloads do not lock the target
+	lock, err := lockTarget(context.Background(), myKeptn, []*workloadRun{{workload: &Workload{ConcurrencyPolicy: ConcurrencyParallel}}}, serviceURL)
+	assert.NoError(t, err)
+	assert.Nil(t, lock)
+
+	_, err = lockTarget(context.Background(), myKeptn, []*workloadRun{{workload: &Workload{ConcurrencyPolicy: ConcurrencyReject}}}, serviceURL)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "other-context")
+
+	// waiting tests are informed about the test holding the target and leave their worker to other tests
+	pool := workerpool.NewPool(1, 1)
+	defer pool.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	waited := make(chan error, 1)
+	_, err = pool.Submit(func() {
+		_, err := lockTarget(workerpool.NewContext(ctx, pool), myKeptn, []*workloadRun{{}}, serviceURL)
+		waited <- err
+	})
+	assert.NoError(t, err)
+	otherTarget := make(chan struct{})
+	_, err = pool.Submit(func() { close(otherTarget) })
+	assert.NoError(t, err)
+	select {
+	case <-otherTarget:
+	case <-time.After(time.Second):
+		t.Fatal("the waiting test has not left its worker to the other test")
+	}
+	cancel()
+	assert.Equal(t, context.Canceled, <-waited)
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	statusData := &keptnv2.EventData{}
+	assert.NoError(t, eventSender.SentEvents[len(eventSender.SentEvents)-1].DataAs(statusData))
+	assert.Contains(t, statusData.Message, "Waiting for test other-context")
+
+	other.Release()
+	lock, err = lockTarget(context.Background(), myKeptn, []*workloadRun{{}}, serviceURL)
+	assert.NoError(t, err)
+	assert.Equal(t, myKeptn.KeptnContext, targetLocks.Holder(key))
+	lock.Release()
+}
+
+const testStatsCSV = `Type,Name,Request Count,Failure Count,Median Response Time,Average Response Time,Min Response Time,Max Response Time,Average Content Size,Requests/s,Failures/s,50%,66%,75%,80%,90%,95%,98%,99%,99.9%,99.99%,100%
+GET,/carts,100,2,12,14.5,8,40,512,10.5,0.2,12,13,14,15,20,25,30,35,40,40,40
+,Aggregated,100,2,12,14.5,8,40,512,10.5,0.2,12,13,14,15,20,25,30,35,40,40,40
+`
+
+const testFailuresCSV = `Method,Name,Error,Occurrences
+GET,/carts,"HTTPError('500 Server Error')",2
+`
+
+// setupHandlerTest runs the test in a directory containing the default locustfile, as resources are read from the
+// local filesystem by the test objects, and stores the results in a temporary result store
+func setupHandlerTest(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "handler")
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "locust"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, DefaultLocustFilename), []byte(""), 0644))
+
+	workingDir, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+
+	previousStore := resultStore
+	resultStore = results.NewStore(filepath.Join(dir, "results"))
+	previousWorkspaces := workspaces
+	workspaces = workspace.NewManager(filepath.Join(dir, "workspaces"), workspace.Policy{Cleanup: workspace.CleanupOnSuccess})
+
+	return func() {
+		resultStore = previousStore
+		workspaces = previousWorkspaces
+		os.Chdir(workingDir)
+		os.RemoveAll(dir)
+	}
+}
+
+// serveResources serves the given resources of the service like the configuration service and lets the keptn handler
+// fetch its resources from there, as it only passes the name of a resource when reading from the local filesystem
+func serveResources(t *testing.T, myKeptn *keptnv2.Keptn, resources map[string]string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := strings.Index(r.URL.Path, "/resource/")
+		if r.Method != http.MethodGet || index < 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		uri := r.URL.Path[index+len("/resource/"):]
+		content, ok := resources[uri]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&models.Resource{
+			ResourceURI:     &uri,
+			ResourceContent: base64.StdEncoding.EncodeToString([]byte(content)),
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	myKeptn.UseLocalFileSystem = false
+	myKeptn.ResourceHandler = api.NewResourceHandler(server.URL)
+}
+
+func TestHandleTestTriggeredEvent(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+	openTestHistory(t)
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	fakeRunner := &runner.Fake{Files: map[string]string{"locust_stats.csv": testStatsCSV}, Output: "All users spawned\n"}
+	assert.NoError(t, HandleTestTriggeredEvent(context.Background(), fakeRunner, myKeptn, *incomingEvent, data))
+
+	specs := fakeRunner.Specs()
+	assert.Len(t, specs, 1)
+	assert.Equal(t, incomingEvent.ID(), specs[0].ID)
+	assert.Contains(t, specs[0].Args, "--host=http://carts.sockshop-dev:80")
+	assert.Contains(t, specs[0].Args, "--users=10")
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	finishedEvent := eventSender.SentEvents[len(eventSender.SentEvents)-1]
+	assert.Equal(t, keptnv2.GetFinishedEventType(keptnv2.TestTaskName), finishedEvent.Type())
+	finishedData := &TestFinishedEventData{}
+	assert.NoError(t, finishedEvent.DataAs(finishedData))
+	assert.Equal(t, keptnv2.StatusSucceeded, finishedData.Status)
+	assert.Equal(t, keptnv2.ResultPass, finishedData.Result)
+	assert.Equal(t, int64(100), finishedData.Locust.RequestCount)
+
+	statistics, err := resultStore.LoadStatistics(resultKey(myKeptn))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), statistics.Aggregated.FailureCount)
+
+	// the end of the output is kept in the run history for successful runs as well
+	run, err := runHistory.Get(incomingEvent.ID())
+	assert.NoError(t, err)
+	assert.Equal(t, "All users spawned\n", run.Output)
+
+	// the workspace of a successful test is removed
+	infos, err := workspaces.List()
+	assert.NoError(t, err)
+	assert.Empty(t, infos)
+}
+
+func TestHandleTestTriggeredEvent_Failed(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	fakeRunner := &runner.Fake{Err: errors.New("locust exited with 1"), Files: map[string]string{LocustReportFilename: "<html></html>"}}
+	assert.Error(t, HandleTestTriggeredEvent(context.Background(), fakeRunner, myKeptn, *incomingEvent, data))
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	finishedData := &TestFinishedEventData{}
+	assert.NoError(t, eventSender.SentEvents[len(eventSender.SentEvents)-1].DataAs(finishedData))
+	assert.Equal(t, keptnv2.StatusErrored, finishedData.Status)
+	assert.Equal(t, "locust exited with 1", finishedData.Message)
+
+	// the HTML report of the failed run is kept, it cannot be uploaded with the local filesystem
+	report, err := resultStore.LoadArtifact(resultKey(myKeptn), LocustReportFilename)
+	assert.NoError(t, err)
+	assert.Equal(t, "<html></html>", string(report))
+
+	// the JUnit report lists the error of the run
+	report, err = resultStore.LoadArtifact(resultKey(myKeptn), junit.Filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(report), `<error message="locust exited with 1" type="run">`)
+
+	// the workspace of a failed test is kept for debugging
+	infos, err := workspaces.List()
+	assert.NoError(t, err)
+	assert.Len(t, infos, 1)
+	assert.Equal(t, myKeptn.KeptnContext, infos[0].KeptnContext)
+	assert.Equal(t, incomingEvent.ID(), infos[0].TriggeredID)
+	assert.False(t, infos[0].Succeeded)
+}
+
+func TestHandleTestTriggeredEvent_FailedRequests(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	otherKeptn, otherEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+	serveResources(t, myKeptn, map[string]string{
+		LocustConfFilename: "workloads:\n  - teststrategy: performance\n    script: locust/load.py\n    thresholds:\n      - max_failure_ratio: 0.01\n",
+		"locust/load.py":   "from locust import HttpUser",
+	})
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	// locust exits with 1 if any request has failed
+	exitErr := exec.Command("sh", "-c", "exit 1").Run()
+	fakeRunner := &runner.Fake{Err: exitErr, Files: map[string]string{"locust_stats.csv": testStatsCSV, "locust_failures.csv": testFailuresCSV}}
+	assert.NoError(t, HandleTestTriggeredEvent(context.Background(), fakeRunner, myKeptn, *incomingEvent, data))
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	finishedData := &TestFinishedEventData{}
+	assert.NoError(t, eventSender.SentEvents[len(eventSender.SentEvents)-1].DataAs(finishedData))
+	assert.Equal(t, keptnv2.StatusSucceeded, finishedData.Status)
+	assert.Equal(t, keptnv2.ResultFailed, finishedData.Result)
+	assert.Equal(t, int64(2), finishedData.Locust.FailureCount)
+	assert.Len(t, finishedData.Violations, 1)
+
+	// the failed requests recorded by locust are failures of the endpoint in the JUnit report
+	report, err := resultStore.LoadArtifact(resultKey(myKeptn), junit.Filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(report), `type="locust"`)
+	assert.Contains(t, string(report), `type="threshold"`)
+
+	// without statistics the exit code means locust could not run the test
+	assert.Error(t, HandleTestTriggeredEvent(context.Background(), &runner.Fake{Err: exitErr}, otherKeptn, *otherEvent, data))
+
+	eventSender = otherKeptn.EventSender.(*fake.EventSender)
+	assert.NoError(t, eventSender.SentEvents[len(eventSender.SentEvents)-1].DataAs(finishedData))
+	assert.Equal(t, keptnv2.StatusErrored, finishedData.Status)
+}
+
+func TestHandleTestTriggeredEvent_InvalidLocustConf(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+	serveResources(t, myKeptn, map[string]string{
+		LocustConfFilename: "execution: random\nworkloads:\n  - teststrategy: performance\n",
+	})
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	fakeRunner := &runner.Fake{}
+	assert.Error(t, HandleTestTriggeredEvent(context.Background(), fakeRunner, myKeptn, *incomingEvent, data))
+	assert.Empty(t, fakeRunner.Specs())
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	finishedData := &TestFinishedEventData{}
+	assert.NoError(t, eventSender.SentEvents[len(eventSender.SentEvents)-1].DataAs(finishedData))
+	assert.Equal(t, keptnv2.StatusErrored, finishedData.Status)
+	assert.Equal(t, keptnv2.ResultFailed, finishedData.Result)
+	assert.Contains(t, finishedData.Message, "invalid execution random")
+}
+
+func TestHandleTestTriggeredEvent_NoDeploymentURI(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+	data.Deployment.DeploymentURIsPublic = nil
+	data.Deployment.DeploymentURIsLocal = nil
+
+	// the test fails without running locust against a missing target
+	fakeRunner := &runner.Fake{}
+	assert.Error(t, HandleTestTriggeredEvent(context.Background(), fakeRunner, myKeptn, *incomingEvent, data))
+	assert.Empty(t, fakeRunner.Specs())
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	assert.NoError(t, eventSender.AssertSentEventTypes([]string{
+		keptnv2.GetStartedEventType(keptnv2.TestTaskName),
+		keptnv2.GetFinishedEventType(keptnv2.TestTaskName),
+	}))
+	finishedData := &keptnv2.TestFinishedEventData{}
+	assert.NoError(t, eventSender.SentEvents[1].DataAs(finishedData))
+	assert.Equal(t, keptnv2.StatusErrored, finishedData.Status)
+	assert.Equal(t, "no deployment URI included in event", finishedData.Message)
+}
+
+func TestHandleTestTriggeredEvent_Timeout(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+
+	serveResources(t, myKeptn, map[string]string{
+		LocustConfFilename: "workloads:\n  - teststrategy: performance\n    script: locust/load.py\n    timeout: 100ms\n",
+		"locust/load.py":   "from locust import HttpUser",
+	})
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	// locust writes the statistics collected so far when it is stopped
+	fakeRunner := &runner.Fake{Block: true, Files: map[string]string{"locust_stats.csv": testStatsCSV}}
+	assert.Error(t, HandleTestTriggeredEvent(context.Background(), fakeRunner, myKeptn, *incomingEvent, data))
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	finishedData := &TestFinishedEventData{}
+	assert.NoError(t, eventSender.SentEvents[len(eventSender.SentEvents)-1].DataAs(finishedData))
+	assert.Equal(t, keptnv2.StatusErrored, finishedData.Status)
+	assert.Equal(t, "Locust test timed out after 100ms", finishedData.Message)
+	assert.Equal(t, int64(100), finishedData.Locust.RequestCount)
+
+	// the JUnit report contains the statistics collected so far and the timeout
+	report, err := resultStore.LoadArtifact(resultKey(myKeptn), junit.Filename)
+	assert.NoError(t, err)
+	assert.Contains(t, string(report), `name="GET /carts"`)
+	assert.Contains(t, string(report), `<error message="Locust test timed out after 100ms" type="run">`)
+}
+
+// scriptRunner records the locustfile content of every run before running it with the Fake runner
+type scriptRunner struct {
+	runner.Fake
+	mutex   sync.Mutex
+	scripts []string
+}
+
+func (s *scriptRunner) Start(spec runner.Spec) (runner.Execution, error) {
+	for _, arg := range spec.Args {
+		if strings.HasPrefix(arg, "-f=") {
+			content, err := ioutil.ReadFile(strings.TrimPrefix(arg, "-f="))
+			if err != nil {
+				return nil, err
+			}
+			s.mutex.Lock()
+			s.scripts = append(s.scripts, string(content))
+			s.mutex.Unlock()
+		}
+	}
+	return s.Fake.Start(spec)
+}
+
+func TestHandleTestTriggeredEvent_SameScriptNames(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+	serveResources(t, myKeptn, map[string]string{
+		LocustConfFilename:              "workloads:\n  - teststrategy: performance\n    name: browse\n    script: locust/browse/locustfile.py\n  - teststrategy: performance\n    name: checkout\n    script: locust/checkout/locustfile.py\n",
+		"locust/browse/locustfile.py":   "# browse",
+		"locust/checkout/locustfile.py": "# checkout",
+	})
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	// every workload runs its own script, although both are named locustfile.py
+	scripts := &scriptRunner{Fake: runner.Fake{Files: map[string]string{"locust_stats.csv": testStatsCSV, "locust-2_stats.csv": testStatsCSV}}}
+	assert.NoError(t, HandleTestTriggeredEvent(context.Background(), scripts, myKeptn, *incomingEvent, data))
+	assert.Equal(t, []string{"# browse", "# checkout"}, scripts.scripts)
+
+	specs := scripts.Specs()
+	assert.Len(t, specs, 2)
+	assert.NotEqual(t, specs[0].WorkDir, specs[1].WorkDir)
+}
+
+func TestHandleTestTriggeredEvent_Virtualenv(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+	assert.NoError(t, ioutil.WriteFile(RequirementsFilename, []byte("faker\n"), 0644))
+
+	// a shell script standing in for python, which copies itself into the virtualenv
+	dir, err := os.Getwd()
+	assert.NoError(t, err)
+	python := filepath.Join(dir, "python")
+	script := `#!/bin/sh
+case "$2" in
+  venv) mkdir -p "$4/bin" && cp "$0" "$4/bin/python" ;;
+  pip) echo "Successfully installed faker" ;;
+esac
+`
+	assert.NoError(t, ioutil.WriteFile(python, []byte(script), 0755))
+
+	previousVirtualenvs := virtualenvs
+	virtualenvs = virtualenv.NewManager(filepath.Join(dir, "virtualenvs"), 1)
+	virtualenvs.Python = python
+	defer func() {
+		virtualenvs = previousVirtualenvs
+	}()
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	fakeRunner := &runner.Fake{Files: map[string]string{"locust_stats.csv": testStatsCSV}}
+	assert.NoError(t, HandleTestTriggeredEvent(context.Background(), fakeRunner, myKeptn, *incomingEvent, data))
+
+	specs := fakeRunner.Specs()
+	assert.Len(t, specs, 1)
+	assert.Len(t, specs[0].Command, 3)
+	assert.Equal(t, []string{"-m", "locust"}, specs[0].Command[1:])
+	assert.True(t, strings.HasPrefix(specs[0].Command[0], virtualenvs.Dir))
+
+	messages := []string{}
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	for _, event := range eventSender.SentEvents {
+		if event.Type() == keptnv2.GetStatusChangedEventType(keptnv2.TestTaskName) {
+			eventData := &keptnv2.EventData{}
+			assert.NoError(t, event.DataAs(eventData))
+			messages = append(messages, eventData.Message)
+		}
+	}
+	assert.Contains(t, strings.Join(messages, "\n"), "Successfully installed faker")
+}
+
+func TestHandleTestTriggeredEvent_VirtualenvsDisabled(t *testing.T) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/test-triggered.json")
+	assert.NoError(t, err)
+	defer setupHandlerTest(t)()
+	assert.NoError(t, ioutil.WriteFile(RequirementsFilename, []byte("faker\n"), 0644))
+
+	previousVirtualenvs := virtualenvs
+	virtualenvs = nil
+	defer func() {
+		virtualenvs = previousVirtualenvs
+	}()
+
+	data := &keptnv2.TestTriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+
+	fakeRunner := &runner.Fake{Files: map[string]string{"locust_stats.csv": testStatsCSV}}
+	assert.NoError(t, HandleTestTriggeredEvent(context.Background(), fakeRunner, myKeptn, *incomingEvent, data))
+
+	// the requirements are not installed, which is reported instead of being ignored silently
+	specs := fakeRunner.Specs()
+	assert.Len(t, specs, 1)
+	assert.Nil(t, specs[0].Command)
+
+	messages := []string{}
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	for _, event := range eventSender.SentEvents {
+		if event.Type() == keptnv2.GetStatusChangedEventType(keptnv2.TestTaskName) {
+			eventData := &keptnv2.EventData{}
+			assert.NoError(t, event.DataAs(eventData))
+			messages = append(messages, eventData.Message)
+		}
+	}
+	assert.Contains(t, strings.Join(messages, "\n"), "Ignoring "+RequirementsFilename)
+}
+
+// setupGetSLITest prepares a get-sli.triggered event answered with the sli.yaml of the test data and the given
+// statistics stored for the stage of the event (none if nil)
+func setupGetSLITest(t *testing.T, statistics *stats.Statistics) (*keptnv2.Keptn, *cloudevents.Event, *keptnv2.GetSLITriggeredEventData) {
+	myKeptn, incomingEvent, err := initializeTestObjects("test-events/get-sli-triggered.json")
+	assert.NoError(t, err)
+	sliConfig, err := ioutil.ReadFile("test-data/sli.yaml")
+	assert.NoError(t, err)
+	t.Cleanup(setupHandlerTest(t))
+	serveResources(t, myKeptn, map[string]string{SliFilename: string(sliConfig)})
+
+	if statistics != nil {
+		assert.NoError(t, resultStore.SaveStatistics(resultKey(myKeptn), statistics))
+	}
+
+	data := &keptnv2.GetSLITriggeredEventData{}
+	assert.NoError(t, incomingEvent.DataAs(data))
+	return myKeptn, incomingEvent, data
+}
+
+// sliStatistics are the statistics of testStatsCSV
+var sliStatistics = &stats.Statistics{
+	Endpoints:  []stats.RequestStats{{Type: "GET", Name: "/carts", RequestCount: 100, FailureCount: 2, P95: 25, P99: 35, RequestsPerSecond: 10.5}},
+	Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 100, FailureCount: 2, P95: 25, P99: 35, RequestsPerSecond: 10.5},
+}
+
+// getSLIFinishedData returns the data of the get-sli.finished event, which must be the last event sent
+func getSLIFinishedData(t *testing.T, myKeptn *keptnv2.Keptn) *keptnv2.GetSLIFinishedEventData {
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	assert.NoError(t, eventSender.AssertSentEventTypes([]string{
+		keptnv2.GetStartedEventType(keptnv2.GetSLITaskName),
+		keptnv2.GetFinishedEventType(keptnv2.GetSLITaskName),
+	}))
+	finishedData := &keptnv2.GetSLIFinishedEventData{}
+	assert.NoError(t, eventSender.SentEvents[1].DataAs(finishedData))
+	return finishedData
+}
+
+func TestHandleGetSliTriggeredEvent(t *testing.T) {
+	myKeptn, incomingEvent, data := setupGetSLITest(t, sliStatistics)
+
+	assert.NoError(t, HandleGetSliTriggeredEvent(myKeptn, *incomingEvent, data))
+
+	// the indicators are computed with the queries of sli.yaml
+	finishedData := getSLIFinishedData(t, myKeptn)
+	assert.Equal(t, keptnv2.StatusSucceeded, finishedData.Status)
+	assert.Equal(t, data.GetSLI.Start, finishedData.GetSLI.Start)
+	assert.Equal(t, []*keptnv2.SLIResult{
+		{Metric: "response_time_p95", Value: 25, Success: true},
+		{Metric: "error_rate", Value: 2, Success: true},
+	}, finishedData.GetSLI.IndicatorValues)
+}
+
+func TestHandleGetSliTriggeredEvent_RawQuery(t *testing.T) {
+	myKeptn, incomingEvent, data := setupGetSLITest(t, sliStatistics)
+	data.GetSLI.Indicators = []string{"p99:GET /carts", "unknown"}
+
+	assert.NoError(t, HandleGetSliTriggeredEvent(myKeptn, *incomingEvent, data))
+
+	// indicators missing in sli.yaml are used as query themselves
+	finishedData := getSLIFinishedData(t, myKeptn)
+	assert.Equal(t, keptnv2.StatusSucceeded, finishedData.Status)
+	assert.Len(t, finishedData.GetSLI.IndicatorValues, 2)
+	assert.Equal(t, &keptnv2.SLIResult{Metric: "p99:GET /carts", Value: 35, Success: true}, finishedData.GetSLI.IndicatorValues[0])
+	assert.Equal(t, "unknown", finishedData.GetSLI.IndicatorValues[1].Metric)
+	assert.False(t, finishedData.GetSLI.IndicatorValues[1].Success)
+	assert.NotEmpty(t, finishedData.GetSLI.IndicatorValues[1].Message)
+}
+
+func TestHandleGetSliTriggeredEvent_NoStatistics(t *testing.T) {
+	myKeptn, incomingEvent, data := setupGetSLITest(t, nil)
+	// the keptnContext has run a test in another stage of the sequence only
+	otherStage := resultKey(myKeptn)
+	otherStage.Stage = "staging"
+	assert.NoError(t, resultStore.SaveStatistics(otherStage, sliStatistics))
+
+	assert.NoError(t, HandleGetSliTriggeredEvent(myKeptn, *incomingEvent, data))
+
+	finishedData := getSLIFinishedData(t, myKeptn)
+	assert.Equal(t, keptnv2.StatusErrored, finishedData.Status)
+	assert.Equal(t, keptnv2.ResultFailed, finishedData.Result)
+	assert.Contains(t, finishedData.Message, "No locust statistics found")
+	assert.Empty(t, finishedData.GetSLI.IndicatorValues)
+}
+
+func TestHandleGetSliTriggeredEvent_OtherProvider(t *testing.T) {
+	myKeptn, incomingEvent, data := setupGetSLITest(t, sliStatistics)
+	data.GetSLI.SLIProvider = "prometheus"
+
+	assert.NoError(t, HandleGetSliTriggeredEvent(myKeptn, *incomingEvent, data))
+
+	eventSender := myKeptn.EventSender.(*fake.EventSender)
+	assert.Empty(t, eventSender.SentEvents)
+}
+
+func TestParseScheduledServices(t *testing.T) {
+	services, err := parseScheduledServices([]string{"sockshop/production/carts", " sockshop/staging/carts "})
+	assert.NoError(t, err)
+	assert.Equal(t, []scheduledService{
+		{project: "sockshop", stage: "production", service: "carts"},
+		{project: "sockshop", stage: "staging", service: "carts"},
+	}, services)
+
+	_, err = parseScheduledServices([]string{"sockshop/carts"})
+	assert.Error(t, err)
+}
+
+func TestNewScheduledRuns(t *testing.T) {
+	entry := &schedule.Entry{Project: "sockshop", Stage: "production", Service: "carts", Schedule: &schedule.Schedule{Name: "warm-caches", Workload: "background"}}
+	locustConf := &LocustConf{Workloads: []*Workload{
+		{Name: "checkout", TestStrategy: "performance"},
+		{Name: "background", TestStrategy: "synthetic"},
+	}}
+
+	runs, err := newScheduledRuns(locustConf, entry, "id")
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "id", runs[0].id)
+	assert.Equal(t, "synthetic", runs[0].workload.TestStrategy)
+	assert.Equal(t, "sockshop/production/carts/warm-caches", runs[0].schedule)
+
+	_, err = newScheduledRuns(&LocustConf{}, entry, "id")
+	assert.Error(t, err)
+	_, err = newScheduledRuns(nil, entry, "id")
+	assert.Error(t, err)
+}
+
+func TestRequireToken(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := requireToken("secret", next)
+
+	serve := func(method string, authorization string) int {
+		request := httptest.NewRequest(method, "/runs/1234", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodGet, ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, ""))
+	assert.Equal(t, http.StatusUnauthorized, serve(http.MethodDelete, "Bearer wrong"))
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "Bearer secret"))
+
+	// without token every request passes
+	recorder := httptest.NewRecorder()
+	requireToken("", next).ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/runs/1234", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+}
+
+func TestLoadSchedules(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		uri := schedule.Filename
+		json.NewEncoder(w).Encode(&models.Resource{
+			ResourceURI:     &uri,
+			ResourceContent: base64.StdEncoding.EncodeToString([]byte("schedules:\n  - name: warm-caches\n    cron: \"@every 30m\"\n    workload: background\n    host: http://carts.sockshop-production\n")),
+		})
+	}))
+	defer server.Close()
+
+	previousOptions := keptnOptions
+	keptnOptions.UseLocalFileSystem = false
+	keptnOptions.ConfigurationServiceURL = server.URL
+	defer func() {
+		keptnOptions = previousOptions
+	}()
+
+	scheduler := schedule.New(func(entry *schedule.Entry) {})
+	services := []scheduledService{{project: "sockshop", stage: "production", service: "carts"}}
+	loadSchedules(scheduler, services)
+	assert.Contains(t, scheduler.Entries(), "sockshop/production/carts/warm-caches")
+
+	// the schedules are kept while the configuration service is unavailable
+	status = http.StatusInternalServerError
+	loadSchedules(scheduler, services)
+	assert.Contains(t, scheduler.Entries(), "sockshop/production/carts/warm-caches")
+
+	// a removed schedule.yaml removes the schedules
+	status = http.StatusNotFound
+	loadSchedules(scheduler, services)
+	assert.Empty(t, scheduler.Entries())
+}
+
+func TestNewScheduledKeptn(t *testing.T) {
+	myKeptn, event, data, err := newScheduledKeptn("sockshop", "production", "carts", "http://carts.sockshop-production", "warm-caches")
+	assert.NoError(t, err)
+	assert.Equal(t, "carts", myKeptn.Event.GetService())
+	assert.NotEmpty(t, myKeptn.KeptnContext)
+	assert.Equal(t, keptnv2.GetTriggeredEventType(keptnv2.TestTaskName), event.Type())
+
+	serviceURL, err := getServiceURL(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "carts.sockshop-production", serviceURL.Host)
+
+	// the events of scheduled tests are not sent to Keptn
+	_, err = myKeptn.SendTaskStartedEvent(&keptnv2.EventData{}, ServiceName)
+	assert.NoError(t, err)
+}
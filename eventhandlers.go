@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -8,13 +9,35 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v3"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2" // make sure to use v2 cloudevents here
+	"github.com/keptn-sandbox/locust-service/pkg/baseline"
 	env "github.com/keptn-sandbox/locust-service/pkg/environment"
+	"github.com/keptn-sandbox/locust-service/pkg/history"
+	"github.com/keptn-sandbox/locust-service/pkg/junit"
+	"github.com/keptn-sandbox/locust-service/pkg/kubejob"
+	"github.com/keptn-sandbox/locust-service/pkg/logstream"
+	"github.com/keptn-sandbox/locust-service/pkg/progress"
+	"github.com/keptn-sandbox/locust-service/pkg/readiness"
+	"github.com/keptn-sandbox/locust-service/pkg/results"
+	"github.com/keptn-sandbox/locust-service/pkg/runner"
+	"github.com/keptn-sandbox/locust-service/pkg/schedule"
+	"github.com/keptn-sandbox/locust-service/pkg/shape"
+	"github.com/keptn-sandbox/locust-service/pkg/sli"
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/keptn-sandbox/locust-service/pkg/targetlock"
+	"github.com/keptn-sandbox/locust-service/pkg/thresholds"
+	"github.com/keptn-sandbox/locust-service/pkg/virtualenv"
+	"github.com/keptn-sandbox/locust-service/pkg/workerpool"
+	"github.com/keptn/go-utils/pkg/api/models"
 	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
 	k8sutils "github.com/keptn/kubernetes-utils/pkg"
 )
@@ -30,21 +53,259 @@ const (
 	LocustConfFilename = "locust/locust.conf.yaml"
 	// DefaultLocustFilename defines the path to the default locustfile.py
 	DefaultLocustFilename = "locust/locustfile.py"
+	// SliFilename defines the path to the sli.yaml containing the SLI queries
+	SliFilename = "locust/sli.yaml"
+	// SliProviderName is the name of the SLI provider this service answers get-sli events for
+	SliProviderName = "locust"
+	// LocustReportsDir defines the folder in the config repo the HTML reports are uploaded to
+	LocustReportsDir = "locust/reports"
+	// LocustReportFilename defines the name of the HTML report locust writes into the workload directory
+	LocustReportFilename = "report.html"
+	// LocustReportLabel defines the label of the test.finished event pointing to the uploaded HTML report
+	LocustReportLabel = "locust-report"
+	// AbortedMessage is the message of the test.finished event of a canceled test
+	AbortedMessage = "Locust test aborted"
+	// RunStatusAborted is the status of a canceled test in the run history
+	RunStatusAborted = "aborted"
+	// RunStatusQueued is the status of a test in the run history that has not been started yet
+	RunStatusQueued = "queued"
+	// RunStatusRunning is the status of a run in the run history while locust is running
+	RunStatusRunning = "running"
+	// LocustCSVPrefix defines the prefix of the CSV statistics files locust writes into the workload directory
+	LocustCSVPrefix = "locust"
+	// WorkloadDirname defines the directory of the temp directory holding the files of a workload, locust is run in it
+	WorkloadDirname = "workload"
+	// ExecutionSequential runs the workloads of a test strategy one after the other
+	ExecutionSequential = "sequential"
+	// ExecutionParallel runs the workloads of a test strategy at the same time
+	ExecutionParallel = "parallel"
+	// RequirementsFilename defines the path to the Python requirements of the locustfiles
+	RequirementsFilename = "locust/requirements.txt"
+	// ConcurrencyWait queues the test until no other test runs against the same target
+	ConcurrencyWait = "wait"
+	// ConcurrencyReject rejects the test if another test runs against the same target
+	ConcurrencyReject = "reject"
+	// ConcurrencyParallel runs the test regardless of other tests against the same target
+	ConcurrencyParallel = "parallel"
+	// TargetKeyService considers tests of the same project, stage and service to run against the same target
+	TargetKeyService = "service"
+	// TargetKeyHost considers tests of the same resolved host to run against the same target
+	TargetKeyHost = "host"
 )
 
 // LocustConf Configuration file type
 type LocustConf struct {
 	SpecVersion string      `json:"spec_version" yaml:"spec_version"`
 	Workloads   []*Workload `json:"workloads" yaml:"workloads"`
+	// Execution defines whether the workloads matching the test strategy run sequential (default) or parallel
+	Execution string `json:"execution" yaml:"execution"`
+	// Readiness defines the probe the target has to pass before the workloads are started
+	Readiness *readiness.Config `json:"readiness" yaml:"readiness"`
 }
 
 // Workload of Keptn stage
 type Workload struct {
+	// Name identifies the workload in the results if several workloads share the test strategy
+	Name         string `json:"name" yaml:"name"`
 	TestStrategy string `json:"teststrategy" yaml:"teststrategy"`
 	Script       string `json:"script" yaml:"script"`
 	Conf         string `json:"conf" yaml:"conf"`
+	// Thresholds decide whether the test run passes, fails or ends with a warning
+	Thresholds []*thresholds.Threshold `json:"thresholds" yaml:"thresholds"`
+	// Timeout defines how long locust may run before it is stopped (e.g., 15m), limited by the MAX_TIMEOUT of the service
+	Timeout string `json:"timeout" yaml:"timeout"`
+	// Workers defines the number of locust worker processes, if set the test is run distributed
+	Workers *int `json:"workers" yaml:"workers"`
+	// Baseline enables the comparison against the previous successful runs of the same workload
+	Baseline *baseline.Config `json:"baseline" yaml:"baseline"`
+
+	// The load parameters are passed to locust as command line flags and take precedence over the conf file
+
+	// Users defines the peak number of concurrent users (--users)
+	Users *int `json:"users" yaml:"users"`
+	// SpawnRate defines the number of users started per second (--spawn-rate)
+	SpawnRate *float64 `json:"spawn_rate" yaml:"spawn_rate"`
+	// RunTime defines how long the test runs in the locust format, e.g. 300s, 20m, 1h30m (--run-time)
+	RunTime string `json:"run_time" yaml:"run_time"`
+	// StopTimeout defines how long running tasks may take to complete when the test stops, e.g. 30s (--stop-timeout)
+	StopTimeout string `json:"stop_timeout" yaml:"stop_timeout"`
+	// Tags restricts the test to tasks with any of the tags (--tags)
+	Tags []string `json:"tags" yaml:"tags"`
+	// ExcludeTags excludes tasks with any of the tags from the test (--exclude-tags)
+	ExcludeTags []string `json:"exclude_tags" yaml:"exclude_tags"`
+	// Shape generates a load shape from stages or a built-in profile, it replaces users, spawn_rate and run_time
+	Shape *shape.Config `json:"shape" yaml:"shape"`
+	// ConcurrencyPolicy defines what happens if another test runs against the same target: wait (default),
+	// reject or parallel
+	ConcurrencyPolicy string `json:"concurrency_policy" yaml:"concurrency_policy"`
 }
 
+// LoadProfile holds the service-wide defaults and limits of the load parameters
+type LoadProfile struct {
+	// DefaultUsers is used if neither the workload nor a conf file define the users
+	DefaultUsers int
+	// DefaultSpawnRate is used if neither the workload nor a conf file define the spawn rate (0 uses the default of locust)
+	DefaultSpawnRate float64
+	// DefaultRunTime is used if neither the workload nor a conf file define the run time
+	DefaultRunTime string
+	// MaxUsers rejects tests with more users (0 means no limit)
+	MaxUsers int
+	// MaxRunTime rejects tests running longer (0 means no limit)
+	MaxRunTime time.Duration
+}
+
+// locustTimespan matches the time spans locust accepts, e.g. 300s, 20m, 3h or 1h30m
+var locustTimespan = regexp.MustCompile(`^(\d+h)?(\d+m)?(\d+s)?$`)
+
+// validate checks the settings of the workload
+func (w *Workload) validate() error {
+	for _, threshold := range w.Thresholds {
+		if err := threshold.Validate(); err != nil {
+			return fmt.Errorf("invalid threshold: %s", err.Error())
+		}
+	}
+	if w.Timeout != "" {
+		if timeout, err := time.ParseDuration(w.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout: %s", w.Timeout)
+		}
+	}
+	if w.Workers != nil && *w.Workers < 0 {
+		return fmt.Errorf("invalid number of workers: %d", *w.Workers)
+	}
+	if w.Baseline != nil {
+		if err := w.Baseline.Validate(); err != nil {
+			return fmt.Errorf("invalid baseline: %s", err.Error())
+		}
+	}
+	if w.Users != nil && *w.Users < 1 {
+		return fmt.Errorf("invalid number of users: %d", *w.Users)
+	}
+	if w.SpawnRate != nil && *w.SpawnRate <= 0 {
+		return fmt.Errorf("invalid spawn_rate: %g", *w.SpawnRate)
+	}
+	if w.RunTime != "" && !isLocustTimespan(w.RunTime) {
+		return fmt.Errorf("invalid run_time: %s", w.RunTime)
+	}
+	if w.RunTime != "" && maxTimeout > 0 && timespanDuration(w.RunTime) > maxTimeout {
+		return fmt.Errorf("run_time %s exceeds the maximum timeout of %s", w.RunTime, maxTimeout)
+	}
+	if w.StopTimeout != "" && !isLocustTimespan(w.StopTimeout) {
+		return fmt.Errorf("invalid stop_timeout: %s", w.StopTimeout)
+	}
+	if w.Shape != nil {
+		if err := w.Shape.Validate(); err != nil {
+			return fmt.Errorf("invalid shape: %s", err.Error())
+		}
+		if duration := w.Shape.TotalDuration(); maxTimeout > 0 && duration > maxTimeout {
+			return fmt.Errorf("duration %s of the shape exceeds the maximum timeout of %s", duration, maxTimeout)
+		}
+		if w.Users != nil || w.SpawnRate != nil || w.RunTime != "" {
+			return errors.New("users, spawn_rate and run_time cannot be combined with shape")
+		}
+	}
+	switch w.ConcurrencyPolicy {
+	case "", ConcurrencyWait, ConcurrencyReject, ConcurrencyParallel:
+	default:
+		return fmt.Errorf("invalid concurrency_policy %s, must be %s, %s or %s", w.ConcurrencyPolicy, ConcurrencyWait, ConcurrencyReject, ConcurrencyParallel)
+	}
+	for _, tag := range append(append([]string{}, w.Tags...), w.ExcludeTags...) {
+		if tag == "" || strings.ContainsAny(tag, " \t\n") {
+			return fmt.Errorf("invalid tag: '%s'", tag)
+		}
+	}
+	return nil
+}
+
+func isLocustTimespan(value string) bool {
+	return value != "" && locustTimespan.MatchString(value)
+}
+
+// timespanSeconds converts a time span in the locust format into seconds
+func timespanSeconds(value string) int {
+	return int(timespanDuration(value).Seconds())
+}
+
+// timespanDuration converts a time span in the locust format into a duration, plain numbers are seconds
+func timespanDuration(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	duration, _ := time.ParseDuration(value)
+	return duration
+}
+
+// loadArguments returns the locust flags for the load parameters of the workload (which may be nil).
+// If withDefaults is set, users, spawn rate and run time fall back to the loadProfile of the service.
+func loadArguments(workload *Workload, withDefaults bool) []string {
+	if workload == nil {
+		workload = &Workload{}
+	}
+	if workload.Shape != nil {
+		// the shape controls users and run time
+		withDefaults = false
+	}
+	arguments := []string{}
+
+	if workload.Users != nil {
+		arguments = append(arguments, fmt.Sprintf("--users=%d", *workload.Users))
+	} else if withDefaults && loadProfile.DefaultUsers > 0 {
+		arguments = append(arguments, fmt.Sprintf("--users=%d", loadProfile.DefaultUsers))
+	}
+	if workload.SpawnRate != nil {
+		arguments = append(arguments, "--spawn-rate="+strconv.FormatFloat(*workload.SpawnRate, 'f', -1, 64))
+	} else if withDefaults && loadProfile.DefaultSpawnRate > 0 {
+		arguments = append(arguments, "--spawn-rate="+strconv.FormatFloat(loadProfile.DefaultSpawnRate, 'f', -1, 64))
+	}
+	if workload.RunTime != "" {
+		arguments = append(arguments, "--run-time="+workload.RunTime)
+	} else if withDefaults && loadProfile.DefaultRunTime != "" {
+		arguments = append(arguments, "--run-time="+loadProfile.DefaultRunTime)
+	}
+	if workload.StopTimeout != "" {
+		// older locust versions only accept seconds
+		arguments = append(arguments, fmt.Sprintf("--stop-timeout=%d", timespanSeconds(workload.StopTimeout)))
+	}
+	if len(workload.Tags) > 0 {
+		arguments = append(append(arguments, "--tags"), workload.Tags...)
+	}
+	if len(workload.ExcludeTags) > 0 {
+		arguments = append(append(arguments, "--exclude-tags"), workload.ExcludeTags...)
+	}
+	return arguments
+}
+
+// TestFinishedEventData extends the test.finished event data with the statistics of the locust run
+type TestFinishedEventData struct {
+	keptnv2.TestFinishedEventData
+	Locust *stats.Summary `json:"locust,omitempty"`
+	// Violations lists every threshold of the workload that has not been met
+	Violations []thresholds.Violation `json:"violations,omitempty"`
+	// Baseline compares the run against the previous successful runs
+	Baseline *baseline.Comparison `json:"baseline,omitempty"`
+	// Workloads breaks the result down per workload, if several workloads share the test strategy
+	Workloads []*WorkloadResult `json:"workloads,omitempty"`
+}
+
+// WorkloadResult is the result of a single workload of the test
+type WorkloadResult struct {
+	Name       string                 `json:"name"`
+	RunID      string                 `json:"runId,omitempty"`
+	Status     keptnv2.StatusType     `json:"status"`
+	Result     keptnv2.ResultType     `json:"result"`
+	Message    string                 `json:"message"`
+	Locust     *stats.Summary         `json:"locust,omitempty"`
+	Violations []thresholds.Violation `json:"violations,omitempty"`
+	Baseline   *baseline.Comparison   `json:"baseline,omitempty"`
+	// Report is the URI of the uploaded HTML report
+	Report string `json:"report,omitempty"`
+
+	labels map[string]string
+	err    error
+}
+
+// errInvalidLocustConf is returned by getLocustConf if the locust.conf.yaml exists but cannot be parsed or is invalid
+var errInvalidLocustConf = errors.New("invalid " + LocustConfFilename)
+
 // Loads locust.conf for the current service
 func getLocustConf(myKeptn *keptnv2.Keptn, project string, stage string, service string) (*LocustConf, error) {
 	var err error
@@ -66,8 +327,7 @@ func getLocustConf(myKeptn *keptnv2.Keptn, project string, stage string, service
 	var locustConf *LocustConf
 	locustConf, err = parseLocustConf(keptnResourceContent)
 	if err != nil {
-		logMessage := fmt.Sprintf("Couldn't parse %s file found for service %s in stage %s in project %s. Error: %s", LocustConfFilename, service, stage, project, err.Error())
-		return nil, errors.New(logMessage)
+		return nil, fmt.Errorf("%w: couldn't parse %s file found for service %s in stage %s in project %s. Error: %s", errInvalidLocustConf, LocustConfFilename, service, stage, project, err.Error())
 	}
 
 	log.Printf("Successfully loaded locust.conf.yaml with %d workloads", len(locustConf.Workloads))
@@ -84,7 +344,8 @@ func getAllLocustResources(myKeptn *keptnv2.Keptn, project string, stage string,
 	}
 
 	for _, resource := range resources {
-		if strings.Contains(*resource.ResourceURI, "locust/") && !strings.HasSuffix(*resource.ResourceURI, ".conf") {
+		if strings.Contains(*resource.ResourceURI, "locust/") && !strings.HasSuffix(*resource.ResourceURI, ".conf") &&
+			!strings.Contains(*resource.ResourceURI, LocustReportsDir+"/") {
 			_, err := getKeptnResource(myKeptn, *resource.ResourceURI, tempDir)
 
 			if err != nil {
@@ -96,6 +357,136 @@ func getAllLocustResources(myKeptn *keptnv2.Keptn, project string, stage string,
 	return nil
 }
 
+// checkLoadLimits rejects tests exceeding the maximum users or run time of the loadProfile. The load parameters of
+// the workload (which may be nil) take precedence over the locust conf file (which may be empty).
+// Tests without any run time are limited to the maximum run time by the returned arguments.
+func checkLoadLimits(workload *Workload, confFile string) ([]string, error) {
+	if workload == nil {
+		workload = &Workload{}
+	}
+	if workload.Shape != nil {
+		if users := workload.Shape.PeakUsers(); loadProfile.MaxUsers > 0 && users > loadProfile.MaxUsers {
+			return nil, fmt.Errorf("%d users of the shape exceed the maximum of %d users allowed by the service", users, loadProfile.MaxUsers)
+		}
+		if duration := workload.Shape.TotalDuration(); loadProfile.MaxRunTime > 0 && duration > loadProfile.MaxRunTime {
+			return nil, fmt.Errorf("duration %s of the shape exceeds the maximum of %s allowed by the service", duration, loadProfile.MaxRunTime)
+		}
+		return []string{}, nil
+	}
+	var conf map[string]string
+	if confFile != "" {
+		conf = readLocustConfValues(confFile)
+	}
+
+	users := 0
+	if workload.Users != nil {
+		users = *workload.Users
+	} else if value, ok := conf["users"]; ok {
+		users, _ = strconv.Atoi(value)
+	} else if confFile == "" {
+		users = loadProfile.DefaultUsers
+	}
+	if loadProfile.MaxUsers > 0 && users > loadProfile.MaxUsers {
+		return nil, fmt.Errorf("%d users exceed the maximum of %d users allowed by the service", users, loadProfile.MaxUsers)
+	}
+
+	runTime := locustRunTime(workload, conf, confFile)
+	if runTime != "" && maxTimeout > 0 && timespanDuration(runTime) > maxTimeout {
+		return nil, fmt.Errorf("run time %s exceeds the maximum timeout of %s", runTime, maxTimeout)
+	}
+	if loadProfile.MaxRunTime <= 0 {
+		return []string{}, nil
+	}
+	if runTime == "" {
+		// without run time locust would run until it is stopped
+		return []string{fmt.Sprintf("--run-time=%ds", int(loadProfile.MaxRunTime.Seconds()))}, nil
+	}
+	if timespanDuration(runTime) > loadProfile.MaxRunTime {
+		return nil, fmt.Errorf("run time %s exceeds the maximum of %s allowed by the service", runTime, loadProfile.MaxRunTime)
+	}
+	return []string{}, nil
+}
+
+// locustRunTime returns the run time locust uses with the workload and the settings of the conf file (which may be
+// empty), an empty run time means locust runs until it is stopped
+func locustRunTime(workload *Workload, conf map[string]string, confFile string) string {
+	if workload.RunTime != "" {
+		return workload.RunTime
+	}
+	if value, ok := conf["run-time"]; ok {
+		return value
+	}
+	if confFile == "" {
+		return loadProfile.DefaultRunTime
+	}
+	return ""
+}
+
+// expectedRunTime returns how long locust runs with the workload (which may be nil) and the conf file (which may be
+// empty), including the limit of the loadProfile (0 means locust runs until it is stopped)
+func expectedRunTime(workload *Workload, confFile string) time.Duration {
+	if workload == nil {
+		workload = &Workload{}
+	}
+	if workload.Shape != nil {
+		return workload.Shape.TotalDuration()
+	}
+	var conf map[string]string
+	if confFile != "" {
+		conf = readLocustConfValues(confFile)
+	}
+	runTime := timespanDuration(locustRunTime(workload, conf, confFile))
+	if loadProfile.MaxRunTime > 0 && (runTime <= 0 || runTime > loadProfile.MaxRunTime) {
+		return loadProfile.MaxRunTime
+	}
+	return runTime
+}
+
+// readLocustConfValues reads the settings of a locust conf file (key = value), keys are normalized to use dashes
+func readLocustConfValues(filename string) map[string]string {
+	values := map[string]string{}
+	input, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Printf("Could not read locust conf file %s: %s", filename, err.Error())
+		return values
+	}
+
+	for _, line := range strings.Split(string(input), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.Replace(strings.TrimSpace(parts[0]), "_", "-", -1)
+		values[key] = strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+	}
+	return values
+}
+
+// writeShapeLocustfile generates the locustfile running the user classes of the workload with the given shape into
+// the given file and returns its path. The user classes are loaded from the script or from the locustfile of the conf.
+func writeShapeLocustfile(config *shape.Config, locustfile string, confFile string, filename string) (string, error) {
+	if locustfile == "" && confFile != "" {
+		locustfile = readLocustConfValues(confFile)["locustfile"]
+	}
+	if locustfile == "" {
+		return "", errors.New("the workload has neither a script nor a conf file referencing a locustfile")
+	}
+
+	content, err := shape.Generate(config, locustfile)
+	if err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(filename, content, 0644); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
 // parses content and maps it to the LocustConf struct
 func parseLocustConf(input []byte) (*LocustConf, error) {
 	locustconf := &LocustConf{}
@@ -104,6 +495,31 @@ func parseLocustConf(input []byte) (*LocustConf, error) {
 		return nil, err
 	}
 
+	switch locustconf.Execution {
+	case "", ExecutionSequential, ExecutionParallel:
+	default:
+		return nil, fmt.Errorf("invalid execution %s, must be %s or %s", locustconf.Execution, ExecutionSequential, ExecutionParallel)
+	}
+	if locustconf.Readiness != nil {
+		if err := locustconf.Readiness.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid readiness: %s", err.Error())
+		}
+	}
+
+	names := map[string]bool{}
+	for _, workload := range locustconf.Workloads {
+		if err := workload.validate(); err != nil {
+			return nil, fmt.Errorf("%s in workload %s", err.Error(), workload.TestStrategy)
+		}
+		if workload.Name != "" {
+			key := workload.TestStrategy + "/" + workload.Name
+			if names[key] {
+				return nil, fmt.Errorf("duplicate name %s in workload %s", workload.Name, workload.TestStrategy)
+			}
+			names[key] = true
+		}
+	}
+
 	return locustconf, nil
 }
 
@@ -129,6 +545,7 @@ func getKeptnResource(myKeptn *keptnv2.Keptn, resourceName string, tempDir strin
 	requestedResourceContent, err := myKeptn.GetKeptnResource(resourceName)
 
 	if err != nil {
+		serviceMetrics.ConfigFetchFailed()
 		log.Printf("Failed to fetch file: %s\n", err.Error())
 		return "", err
 	}
@@ -174,15 +591,65 @@ func replaceLocustFileName(filename string, tempDir string) {
 	}
 }
 
-// HandleTestTriggeredEvent handles test.triggered events by calling locust
-func HandleTestTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.TestTriggeredEventData) error {
-	log.Printf("Handling test.triggered Event: %s", incomingEvent.Context.GetID())
+// queueTestTriggeredEvent acknowledges the test.triggered event with a test.started event and queues the test,
+// it returns immediately. If the queue is full, the test is rejected with an errored test.finished event.
+// The test is registered in the runRegistry right away, so it can be canceled while it is still queued.
+func queueTestTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.TestTriggeredEventData) error {
+	// Keptn expects the test.started event right away, not once the test leaves the queue
+	if _, err := myKeptn.SendTaskStartedEvent(&keptnv2.EventData{}, ServiceName); err != nil {
+		log.Printf("Failed to send task started CloudEvent (%s), aborting... \n", err.Error())
+		return err
+	}
 
-	// CAPTURE START TIME
-	startTime := time.Now()
+	ctx, done := runRegistry.Register(context.Background(), incomingEvent.ID(), myKeptn.KeptnContext)
+
+	recordQueuedTest(myKeptn, incomingEvent, data, "")
+	position, err := testQueue.Submit(func() {
+		defer done()
+		err := handleTest(workerpool.NewContext(ctx, testQueue), locustRunner, myKeptn, incomingEvent, data, nil)
+		if err != nil {
+			log.Printf("Failed to handle test.triggered event %s: %s", incomingEvent.ID(), err.Error())
+		}
+		finishQueuedRuns(ctx, myKeptn.KeptnContext, incomingEvent.ID(), err)
+	})
+
+	if err != nil {
+		errMsg := fmt.Sprintf("Rejected locust test, %s", err.Error())
+		log.Println(errMsg)
+		finishQueuedRuns(ctx, myKeptn.KeptnContext, incomingEvent.ID(), errors.New(errMsg))
+		done()
+
+		_, sendErr := myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Message: errMsg,
+		}, ServiceName)
+		if sendErr != nil {
+			log.Printf("Could not send test.finished event: %s", sendErr.Error())
+		}
+		return errors.New(errMsg)
+	}
+
+	if position > 0 {
+		msg := fmt.Sprintf("Locust test queued at position %d", position)
+		log.Println(msg)
+
+		_, err = myKeptn.SendTaskStatusChangedEvent(&keptnv2.EventData{
+			Message: msg,
+		}, ServiceName)
+		if err != nil {
+			log.Printf("Could not send status changed event: %s", err.Error())
+		}
+	}
+
+	return nil
+}
 
-	// Send out a migrate.started CloudEvent
-	// The get-sli.started cloud-event is new since Keptn 0.8.0 and is required to be send when the task is started
+// HandleTestTriggeredEvent handles test.triggered events by sending a test.started event and running locust with the
+// given runner. If the context is canceled, locust is stopped and the test is finished with an aborted message.
+func HandleTestTriggeredEvent(ctx context.Context, locustRunner runner.Runner, myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.TestTriggeredEventData) error {
+	// Send out a test.started CloudEvent
+	// The test.started cloud-event is new since Keptn 0.8.0 and is required to be send when the task is started
 	_, err := myKeptn.SendTaskStartedEvent(&keptnv2.EventData{}, ServiceName)
 
 	if err != nil {
@@ -190,27 +657,65 @@ func HandleTestTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.
 		return err
 	}
 
+	return handleTest(ctx, locustRunner, myKeptn, incomingEvent, data, nil)
+}
+
+// handleTest runs the test of a test.triggered event, the test.started event has been sent before. If the test has
+// been started by a schedule, the workload is selected by the name of the schedule instead of the test strategy and
+// the HTML reports are not uploaded.
+func handleTest(ctx context.Context, locustRunner runner.Runner, myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.TestTriggeredEventData, scheduled *schedule.Entry) error {
+	log.Printf("Handling test.triggered Event: %s", incomingEvent.Context.GetID())
+
+	// CAPTURE START TIME
+	startTime := time.Now()
+
+	if ctx.Err() != nil {
+		// canceled while the test was queued
+		return sendTestAbortedEvent(myKeptn, startTime)
+	}
+
 	serviceURL, err := getServiceURL(data)
 
 	if err != nil {
 		// report error
 		log.Print(err)
 		// send out a test.finished failed CloudEvent
-		myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
+		_, sendErr := myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
 			Status:  keptnv2.StatusErrored,
 			Result:  keptnv2.ResultFailed,
 			Message: err.Error(),
 		}, ServiceName)
-	}
+		if sendErr != nil {
+			log.Printf("Failed to send task finished CloudEvent (%s), aborting...\n", sendErr.Error())
+		}
 
-	var locustFilename string
+		return err
+	}
 
-	// create a tempdir
-	tempDir, err := ioutil.TempDir("", "locust")
+	workspace, err := workspaces.Create(myKeptn.KeptnContext, incomingEvent.ID())
 	if err != nil {
-		log.Fatal(err)
+		errMsg := fmt.Sprintf("Failed to create the workspace: %s", err.Error())
+		log.Println(errMsg)
+
+		_, err = myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Message: errMsg,
+		}, ServiceName)
+		if err != nil {
+			log.Printf("Failed to send task finished CloudEvent (%s), aborting...\n", err.Error())
+		}
+
+		return errors.New(errMsg)
 	}
-	//defer os.RemoveAll(tempDir)
+	// the workspace is removed according to the cleanup policy, failed tests may be kept for debugging
+	succeeded := false
+	defer func() {
+		if err := workspace.Finish(succeeded); err != nil {
+			log.Printf("Could not clean up workspace %s: %s", workspace.Name(), err.Error())
+		}
+	}()
+	tempDir := workspace.Dir
 
 	var locustconf *LocustConf
 	locustconf, err = getLocustConf(myKeptn, myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService())
@@ -218,25 +723,49 @@ func HandleTestTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.
 	if err != nil {
 		log.Printf("Failed to load Configuration file: %s", err.Error())
 	}
+	if errors.Is(err, errInvalidLocustConf) {
+		serviceMetrics.ConfigParseFailed()
+		// running the default workload instead of the configured one would hide the mistake
+		errMsg := err.Error()
+
+		_, err = myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Message: errMsg,
+		}, ServiceName)
+		if err != nil {
+			log.Printf("Failed to send task finished CloudEvent (%s), aborting...\n", err.Error())
+		}
+
+		return errors.New(errMsg)
+	} else if err != nil {
+		serviceMetrics.ConfigFetchFailed()
+	}
 
-	var configFile = ""
+	var runs []*workloadRun
 
-	if locustconf != nil {
-		for _, workload := range locustconf.Workloads {
-			if workload.TestStrategy == data.Test.TestStrategy {
-				if workload.Script != "" {
-					locustFilename = workload.Script
-				} else {
-					locustFilename = ""
-				}
+	if scheduled != nil {
+		runs, err = newScheduledRuns(locustconf, scheduled, incomingEvent.ID())
+		if err != nil {
+			errMsg := fmt.Sprintf("Failed to run schedule %s: %s", scheduled.ID(), err.Error())
+			log.Println(errMsg)
 
-				if workload.Conf != "" {
-					configFile = workload.Conf
-				}
+			_, err = myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
+				Status:  keptnv2.StatusErrored,
+				Result:  keptnv2.ResultFailed,
+				Message: errMsg,
+			}, ServiceName)
+			if err != nil {
+				log.Printf("Failed to send task finished CloudEvent (%s), aborting...\n", err.Error())
 			}
+
+			return errors.New(errMsg)
 		}
+		data.Test.TestStrategy = runs[0].workload.TestStrategy
+	} else if locustconf != nil {
+		runs = newWorkloadRuns(locustconf.Workloads, data.Test.TestStrategy, incomingEvent.ID())
 	} else {
-		locustFilename = DefaultLocustFilename
+		locustFilename := DefaultLocustFilename
 		_, err = getKeptnResource(myKeptn, locustFilename, tempDir)
 		if err != nil {
 			log.Println("No locust.conf.yaml file provided. Default locust file also doesn't exist. Skipping locust tests!")
@@ -257,33 +786,20 @@ func HandleTestTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.
 
 			myKeptn.SendTaskFinishedEvent(finishedEvent, ServiceName)
 
+			succeeded = true
 			return nil
 		}
 		log.Println("No locust.conf.yaml file provided. Continuing with default settings!")
+		runs = []*workloadRun{{id: incomingEvent.ID(), name: locustFilename, locustFilename: locustFilename}}
 	}
 
-	msg := fmt.Sprintf("TestStrategy=%s -> testFile=%s, serviceUrl=%s\n", data.Test.TestStrategy, locustFilename, serviceURL.String())
-	log.Println(msg)
-
-	_, err = myKeptn.SendTaskStatusChangedEvent(&keptnv2.EventData{
-		Message: msg,
-	}, ServiceName)
-
-	if err != nil {
-		log.Printf("Could not send status changed event: %s", err.Error())
-	}
-
-	var locustResouceFilenameLocal = ""
-	if locustFilename != "" {
-		locustResouceFilenameLocal, err = getKeptnResource(myKeptn, locustFilename, tempDir)
+	recordQueuedRuns(myKeptn, incomingEvent, data, runs)
 
-		// FYI you do not need to "fail" if sli.yaml is missing, you can also assume smart defaults like we do
-		// in keptn-contrib/dynatrace-service and keptn-contrib/prometheus-service
-		if err != nil {
-			// failed to fetch sli config file
-			errMsg := fmt.Sprintf("Failed to fetch locust file %s from config repo: %s", locustFilename, err.Error())
+	// every workload is prepared before the first one is started, so parallel workloads do not fetch the same files
+	for _, run := range runs {
+		if err := run.prepare(myKeptn, data, tempDir, serviceURL); err != nil {
+			errMsg := run.describe(err.Error())
 			log.Println(errMsg)
-			// send a get-sli.finished event with status=error and result=failed back to Keptn
 
 			_, err = myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
 				Status:  keptnv2.StatusErrored,
@@ -293,88 +809,152 @@ func HandleTestTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.
 
 			return err
 		}
-
-		log.Println("Successfully fetched locust test file")
 	}
 
-	// Download locust configuration file
-	var locustConfiguration = ""
-	if configFile != "" {
-		locustConfiguration, err = getKeptnResource(myKeptn, configFile, tempDir)
+	environment, err := prepareVirtualenv(ctx, myKeptn)
+	if ctx.Err() != nil {
+		return sendTestAbortedEvent(myKeptn, startTime)
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Failed to install %s: %s", RequirementsFilename, err.Error())
+		log.Println(errMsg)
 
+		_, err = myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Message: errMsg,
+		}, ServiceName)
 		if err != nil {
-			log.Printf("Failed to fetch locust config file %s from config repo: %s \n", configFile, err.Error())
-		} else {
-			fetchErr := getAllLocustResources(myKeptn, myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService(), tempDir)
-
-			if fetchErr != nil {
-				log.Println(fetchErr)
-			}
+			log.Printf("Failed to send task finished CloudEvent (%s), aborting...\n", err.Error())
+		}
 
-			log.Println("Replacing locust configuration")
-			replaceLocustFileName(locustConfiguration, tempDir)
+		return errors.New(errMsg)
+	}
+	if environment != nil {
+		// the virtualenv must not be evicted while locust is running from it
+		defer environment.Release()
+		for _, run := range runs {
+			run.locust = []string{environment.Python(), "-m", "locust"}
 		}
 	}
 
-	command := []string{
-		"--headless", "--only-summary",
-		"--host=" + serviceURL.String(),
+	lock, err := lockTarget(ctx, myKeptn, runs, serviceURL)
+	if ctx.Err() != nil {
+		return sendTestAbortedEvent(myKeptn, startTime)
 	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Rejected locust test: %s", err.Error())
+		log.Println(errMsg)
 
-	if locustResouceFilenameLocal != "" {
-		command = append(command, fmt.Sprintf("-f=%s", locustResouceFilenameLocal))
-	}
+		_, err = myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Message: errMsg,
+		}, ServiceName)
+		if err != nil {
+			log.Printf("Failed to send task finished CloudEvent (%s), aborting...\n", err.Error())
+		}
 
-	if locustConfiguration != "" {
-		command = append(command, fmt.Sprintf("--config=%s", locustConfiguration))
-	} else {
-		// Set default values
-		command = append(command, fmt.Sprintf("--users=%d", 10))
-		command = append(command, fmt.Sprintf("--run-time=%s", "2m"))
+		return errors.New(errMsg)
+	}
+	if lock != nil {
+		defer lock.Release()
 	}
 
-	if locustResouceFilenameLocal == "" && locustConfiguration == "" {
-		log.Println("Neither script nor conf is provided -> skipping tests")
-	} else {
-		log.Println("Prepare environment")
-		kubeClient, _ := k8sutils.GetKubeAPI(true)
-		EnvironmentProvider := env.NewEnvironmentProvider(kubeClient)
-		environment := EnvironmentProvider.PrepareEnvironment(myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService())
-		log.Println("Running locust tests")
-		str, err := ExecuteCommandWithEnv("locust", command, environment)
+	if locustconf != nil && locustconf.Readiness != nil {
+		msg := fmt.Sprintf("Waiting for %s to be ready", serviceURL.String())
+		log.Println(msg)
 
-		log.Println("Finished running locust tests")
-		log.Println(str)
+		_, err = myKeptn.SendTaskStatusChangedEvent(&keptnv2.EventData{
+			Message: msg,
+		}, ServiceName)
+		if err != nil {
+			log.Printf("Could not send status changed event: %s", err.Error())
+		}
 
+		err = readiness.Wait(ctx, serviceURL, locustconf.Readiness)
+		if ctx.Err() != nil {
+			return sendTestAbortedEvent(myKeptn, startTime)
+		}
 		if err != nil {
-			// report error
-			log.Print(err)
-			// send out a test.finished failed CloudEvent
+			// locust would only report connection errors, so the test is not started at all
+			errMsg := fmt.Sprintf("Locust test not started, target not ready: %s", err.Error())
+			log.Println(errMsg)
+
 			_, err = myKeptn.SendTaskFinishedEvent(&keptnv2.EventData{
 				Status:  keptnv2.StatusErrored,
 				Result:  keptnv2.ResultFailed,
-				Message: err.Error(),
+				Message: errMsg,
 			}, ServiceName)
+			if err != nil {
+				log.Printf("Failed to send task finished CloudEvent (%s), aborting...\n", err.Error())
+			}
 
-			return err
+			return errors.New(errMsg)
+		}
+	}
+
+	results := make([]*WorkloadResult, len(runs))
+	if locustconf != nil && locustconf.Execution == ExecutionParallel && len(runs) > 1 {
+		log.Printf("Running %d workloads in parallel", len(runs))
+		var wg sync.WaitGroup
+		for i, run := range runs {
+			wg.Add(1)
+			go func(i int, run *workloadRun) {
+				defer wg.Done()
+				results[i] = run.execute(ctx, locustRunner, myKeptn, data, tempDir)
+			}(i, run)
+		}
+		wg.Wait()
+	} else {
+		for i, run := range runs {
+			if ctx.Err() != nil {
+				// the remaining workloads are not started once the test has been canceled
+				results = results[:i]
+				break
+			}
+			results[i] = run.execute(ctx, locustRunner, myKeptn, data, tempDir)
 		}
 	}
 
+	if ctx.Err() != nil {
+		return sendTestAbortedEvent(myKeptn, startTime)
+	}
+
 	endTime := time.Now()
 
 	// Done
 
-	finishedEvent := &keptnv2.TestFinishedEventData{
-		Test: keptnv2.TestFinishedDetails{
-			Start: startTime.Format(time.RFC3339),
-			End:   endTime.Format(time.RFC3339),
-		},
-		EventData: keptnv2.EventData{
-			Result:  keptnv2.ResultPass,
-			Status:  keptnv2.StatusSucceeded,
-			Message: "Locust test finished successfully",
+	status, result, message := aggregateResults(results)
+	succeeded = status == keptnv2.StatusSucceeded && result != keptnv2.ResultFailed
+	finishedEvent := &TestFinishedEventData{
+		TestFinishedEventData: keptnv2.TestFinishedEventData{
+			Test: keptnv2.TestFinishedDetails{
+				Start: startTime.Format(time.RFC3339),
+				End:   endTime.Format(time.RFC3339),
+			},
+			EventData: keptnv2.EventData{
+				Result:  result,
+				Status:  status,
+				Message: message,
+			},
 		},
 	}
+	for _, workloadResult := range results {
+		for key, value := range workloadResult.labels {
+			if finishedEvent.Labels == nil {
+				finishedEvent.Labels = map[string]string{}
+			}
+			finishedEvent.Labels[key] = value
+		}
+	}
+	if len(results) == 1 {
+		finishedEvent.Locust = results[0].Locust
+		finishedEvent.Violations = results[0].Violations
+		finishedEvent.Baseline = results[0].Baseline
+	} else {
+		finishedEvent.Workloads = results
+	}
 
 	// Finally: send out a test.finished CloudEvent
 	_, err = myKeptn.SendTaskFinishedEvent(finishedEvent, ServiceName)
@@ -384,16 +964,1074 @@ func HandleTestTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.
 		return err
 	}
 
+	for _, workloadResult := range results {
+		if workloadResult.err != nil {
+			return workloadResult.err
+		}
+	}
 	return nil
 }
 
-// borrowed from go-utils, remove when https://github.com/keptn/go-utils/pull/286 is merged and new go-utils version available
-func ExecuteCommandWithEnv(command string, args []string, env []string) (string, error) {
-	cmd := exec.Command(command, args...)
-	cmd.Env = append(cmd.Env, env...)
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return "", fmt.Errorf("Error executing command %s %s: %s\n%s", command, strings.Join(args, " "), err.Error(), string(out))
+// workloadRun is the locust run of a single workload matching the test strategy of a test.triggered event
+type workloadRun struct {
+	// index of the workload among the matching workloads, the output files of the first one keep their names
+	index int
+	// id of the run in the run history
+	id string
+	// name identifies the workload in messages and in the per-workload results
+	name string
+	// shared is set if several workloads match the test strategy
+	shared bool
+	// workload is nil if there is no locust.conf.yaml
+	workload       *Workload
+	locustFilename string
+	configFile     string
+
+	// locust replaces the locust command of the runner, e.g. to run locust from a virtualenv
+	locust []string
+	// schedule is the ID of the schedule that started the run, if it has not been triggered by Keptn
+	schedule string
+
+	// set by prepare
+	dir           string
+	command       []string
+	workerCommand []string
+	csvPrefix     string
+	reportFile    string
+	runTime       time.Duration
+	skip          bool
+}
+
+// newWorkloadRuns creates a run for every workload matching the test strategy, in the order of the locust.conf.yaml.
+// A single run uses the id of the triggering event, several runs are numbered.
+func newWorkloadRuns(workloads []*Workload, testStrategy string, triggeredID string) []*workloadRun {
+	runs := []*workloadRun{}
+	for _, workload := range workloads {
+		if workload.TestStrategy != testStrategy {
+			continue
+		}
+		runs = append(runs, &workloadRun{
+			index:          len(runs),
+			workload:       workload,
+			locustFilename: workload.Script,
+			configFile:     workload.Conf,
+		})
+	}
+
+	if len(runs) == 0 {
+		// without matching workload the test is skipped
+		return []*workloadRun{{id: triggeredID, name: testStrategy}}
+	}
+
+	for _, run := range runs {
+		run.id = triggeredID
+		run.shared = len(runs) > 1
+		if run.shared {
+			run.id = fmt.Sprintf("%s-%d", triggeredID, run.index+1)
+		}
+		run.name = workloadDisplayName(run.workload, run.index)
+	}
+	return runs
+}
+
+// newScheduledRuns returns the run of the workload the schedule refers to by its name
+func newScheduledRuns(locustconf *LocustConf, scheduled *schedule.Entry, triggeredID string) ([]*workloadRun, error) {
+	if locustconf == nil {
+		return nil, fmt.Errorf("no %s found", LocustConfFilename)
+	}
+	for _, workload := range locustconf.Workloads {
+		if workload.Name == scheduled.Schedule.Workload {
+			runs := newWorkloadRuns([]*Workload{workload}, workload.TestStrategy, triggeredID)
+			runs[0].schedule = scheduled.ID()
+			return runs, nil
+		}
 	}
-	return string(out), nil
+	return nil, fmt.Errorf("no workload %s found in %s", scheduled.Schedule.Workload, LocustConfFilename)
+}
+
+// workloadDisplayName returns the name of the workload, falling back to its script or conf file
+func workloadDisplayName(workload *Workload, index int) string {
+	switch {
+	case workload.Name != "":
+		return workload.Name
+	case workload.Script != "":
+		return workload.Script
+	case workload.Conf != "":
+		return workload.Conf
+	default:
+		return fmt.Sprintf("workload %d", index+1)
+	}
+}
+
+// workloadFilename returns the name of an output file of the workload with the given index. The files of the first
+// workload keep their name, the others get the number of the workload appended (e.g., report-2.html).
+func workloadFilename(name string, index int) string {
+	if index == 0 {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(name, ext), index+1, ext)
+}
+
+// describe prefixes the message with the name of the workload, if several workloads share the test strategy
+func (r *workloadRun) describe(message string) string {
+	if !r.shared {
+		return message
+	}
+	return fmt.Sprintf("[%s] %s", r.name, message)
+}
+
+// prepare fetches the locust files of the workload into the temp directory and builds the locust command.
+// It returns an error if the files cannot be fetched or the test would exceed the limits of the service.
+func (r *workloadRun) prepare(myKeptn *keptnv2.Keptn, data *keptnv2.TestTriggeredEventData, tempDir string, serviceURL *url.URL) error {
+	msg := r.describe(fmt.Sprintf("TestStrategy=%s -> testFile=%s, serviceUrl=%s\n", data.Test.TestStrategy, r.locustFilename, serviceURL.String()))
+	log.Println(msg)
+
+	_, err := myKeptn.SendTaskStatusChangedEvent(&keptnv2.EventData{
+		Message: msg,
+	}, ServiceName)
+
+	if err != nil {
+		log.Printf("Could not send status changed event: %s", err.Error())
+	}
+
+	// every workload gets its own directory, so the files of workloads with resources of the same name (e.g.
+	// locust/browse/locustfile.py and locust/checkout/locustfile.py) do not overwrite each other
+	r.dir = filepath.Join(tempDir, workloadFilename(WorkloadDirname, r.index))
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return fmt.Errorf("Failed to create the workload directory: %s", err.Error())
+	}
+
+	var locustResouceFilenameLocal = ""
+	if r.locustFilename != "" {
+		locustResouceFilenameLocal, err = getKeptnResource(myKeptn, r.locustFilename, r.dir)
+
+		// FYI you do not need to "fail" if sli.yaml is missing, you can also assume smart defaults like we do
+		// in keptn-contrib/dynatrace-service and keptn-contrib/prometheus-service
+		if err != nil {
+			return fmt.Errorf("Failed to fetch locust file %s from config repo: %s", r.locustFilename, err.Error())
+		}
+
+		log.Println("Successfully fetched locust test file")
+	}
+
+	// Download locust configuration file
+	var locustConfiguration = ""
+	if r.configFile != "" {
+		locustConfiguration, err = getKeptnResource(myKeptn, r.configFile, r.dir)
+
+		if err != nil {
+			log.Printf("Failed to fetch locust config file %s from config repo: %s \n", r.configFile, err.Error())
+		} else {
+			fetchErr := getAllLocustResources(myKeptn, myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService(), r.dir)
+
+			if fetchErr != nil {
+				log.Println(fetchErr)
+			}
+
+			log.Println("Replacing locust configuration")
+			replaceLocustFileName(locustConfiguration, r.dir)
+		}
+	}
+
+	if r.workload != nil && r.workload.Shape != nil {
+		shapeFile := filepath.Join(r.dir, workloadFilename(shape.Filename, r.index))
+		locustResouceFilenameLocal, err = writeShapeLocustfile(r.workload.Shape, locustResouceFilenameLocal, locustConfiguration, shapeFile)
+		if err != nil {
+			return fmt.Errorf("Failed to generate the load shape: %s", err.Error())
+		}
+	}
+
+	r.csvPrefix = filepath.Join(r.dir, workloadFilename(LocustCSVPrefix, r.index))
+	r.reportFile = filepath.Join(r.dir, workloadFilename(LocustReportFilename, r.index))
+
+	r.command = []string{
+		"--headless", "--only-summary",
+		"--host=" + serviceURL.String(),
+		"--csv=" + r.csvPrefix,
+		"--html=" + r.reportFile,
+	}
+
+	// workers only need to know the locustfile, everything else is controlled by the master
+	r.workerCommand = []string{}
+
+	if locustResouceFilenameLocal != "" {
+		r.command = append(r.command, fmt.Sprintf("-f=%s", locustResouceFilenameLocal))
+		r.workerCommand = append(r.workerCommand, fmt.Sprintf("-f=%s", locustResouceFilenameLocal))
+	}
+
+	if locustConfiguration != "" {
+		r.command = append(r.command, fmt.Sprintf("--config=%s", locustConfiguration))
+		r.workerCommand = append(r.workerCommand, fmt.Sprintf("--config=%s", locustConfiguration))
+	}
+
+	// the load parameters of the workload override the conf file, without conf file the defaults are used
+	r.command = append(r.command, loadArguments(r.workload, locustConfiguration == "")...)
+
+	limitArguments, err := checkLoadLimits(r.workload, locustConfiguration)
+	if err != nil {
+		return fmt.Errorf("Rejected locust test: %s", err.Error())
+	}
+	r.command = append(r.command, limitArguments...)
+	r.runTime = expectedRunTime(r.workload, locustConfiguration)
+
+	r.skip = locustResouceFilenameLocal == "" && locustConfiguration == ""
+	return nil
+}
+
+// concurrencyPolicy returns the strictest concurrency policy of the workloads, workloads without one wait
+func concurrencyPolicy(runs []*workloadRun) string {
+	policy := ConcurrencyParallel
+	for _, run := range runs {
+		runPolicy := ConcurrencyWait
+		if run.workload != nil && run.workload.ConcurrencyPolicy != "" {
+			runPolicy = run.workload.ConcurrencyPolicy
+		}
+		if runPolicy == ConcurrencyReject {
+			return ConcurrencyReject
+		}
+		if runPolicy == ConcurrencyWait {
+			policy = ConcurrencyWait
+		}
+	}
+	return policy
+}
+
+// targetKey identifies the target of the test according to the targetKeyMode
+func targetKey(myKeptn *keptnv2.Keptn, serviceURL *url.URL) string {
+	if targetKeyMode == TargetKeyHost {
+		return serviceURL.Host
+	}
+	return fmt.Sprintf("%s/%s/%s", myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService())
+}
+
+// lockTarget makes sure no other test runs against the target of the test at the same time, depending on the
+// concurrency policy of the workloads. It waits for the target (and reports it via status.changed events) or
+// returns an error if the test is rejected. The returned lock is nil if the workloads may run in parallel.
+// While waiting, the worker of the test is left to other tests, e.g. against other targets.
+func lockTarget(ctx context.Context, myKeptn *keptnv2.Keptn, runs []*workloadRun, serviceURL *url.URL) (*targetlock.Lock, error) {
+	key := targetKey(myKeptn, serviceURL)
+
+	switch concurrencyPolicy(runs) {
+	case ConcurrencyParallel:
+		return nil, nil
+	case ConcurrencyReject:
+		lock, holder := targetLocks.TryAcquire(key, myKeptn.KeptnContext)
+		if lock == nil {
+			return nil, fmt.Errorf("test %s is already running against %s", holder, key)
+		}
+		return lock, nil
+	}
+
+	if lock, _ := targetLocks.TryAcquire(key, myKeptn.KeptnContext); lock != nil {
+		return lock, nil
+	}
+	var lock *targetlock.Lock
+	var err error
+	workerpool.Idle(ctx, func() {
+		lock, err = targetLocks.Acquire(ctx, key, myKeptn.KeptnContext, func(holder string, position int) {
+			msg := fmt.Sprintf("Waiting for test %s against %s to finish (position %d in the queue)", holder, key, position)
+			log.Println(msg)
+
+			_, err := myKeptn.SendTaskStatusChangedEvent(&keptnv2.EventData{
+				Message: msg,
+			}, ServiceName)
+			if err != nil {
+				log.Printf("Could not send status changed event: %s", err.Error())
+			}
+		})
+	})
+	return lock, err
+}
+
+// prepareVirtualenv installs the Python requirements of the locustfiles into a virtualenv, which is reused by all
+// tests with the same requirements. It returns nil if the service has no requirements or virtualenvs are disabled.
+// The installation and the eviction of cached virtualenvs are reported via status.changed events, as well as
+// requirements that are ignored because virtualenvs are disabled (e.g. with the Kubernetes runner).
+func prepareVirtualenv(ctx context.Context, myKeptn *keptnv2.Keptn) (*virtualenv.Environment, error) {
+	sendStatus := func(msg string) {
+		log.Println(msg)
+		_, err := myKeptn.SendTaskStatusChangedEvent(&keptnv2.EventData{
+			Message: msg,
+		}, ServiceName)
+		if err != nil {
+			log.Printf("Could not send status changed event: %s", err.Error())
+		}
+	}
+
+	requirements, err := myKeptn.GetKeptnResource(RequirementsFilename)
+	if err != nil {
+		// there is no way to tell a missing resource from a failed request, locust fails on missing imports anyway
+		log.Printf("No %s found, running locust without virtualenv: %s", RequirementsFilename, err.Error())
+		return nil, nil
+	}
+	if virtualenvs == nil {
+		sendStatus(fmt.Sprintf("Ignoring %s, requirements are only installed by the %s runner with MAX_VIRTUALENVS above 0", RequirementsFilename, RunnerLocal))
+		return nil, nil
+	}
+
+	name := virtualenv.Hash(requirements)[:12]
+	sendStatus(fmt.Sprintf("Preparing virtualenv %s for %s", name, RequirementsFilename))
+	environment, evicted, err := virtualenvs.Ensure(ctx, requirements)
+	if err != nil {
+		return nil, err
+	}
+
+	if environment.Created {
+		sendStatus(fmt.Sprintf("Installed %s into virtualenv %s:\n%s", RequirementsFilename, name, environment.Output))
+	} else {
+		sendStatus(fmt.Sprintf("Using cached virtualenv %s", name))
+	}
+	for _, hash := range evicted {
+		sendStatus(fmt.Sprintf("Evicted virtualenv %s from the cache", hash[:12]))
+	}
+	return environment, nil
+}
+
+// execute runs locust for the prepared workload, evaluates and records the run. If the context is canceled,
+// locust is stopped and the run is recorded as aborted.
+func (r *workloadRun) execute(ctx context.Context, locustRunner runner.Runner, myKeptn *keptnv2.Keptn, data *keptnv2.TestTriggeredEventData, tempDir string) *WorkloadResult {
+	workloadResult := &WorkloadResult{
+		Name:    r.name,
+		Status:  keptnv2.StatusSucceeded,
+		Result:  keptnv2.ResultPass,
+		Message: "Locust test finished successfully",
+	}
+
+	if r.skip {
+		log.Println(r.describe("Neither script nor conf is provided -> skipping tests"))
+		return workloadResult
+	}
+	workloadResult.RunID = r.id
+	startTime := time.Now()
+
+	log.Println("Prepare environment")
+	kubeClient, err := k8sutils.GetKubeAPI(true)
+	EnvironmentProvider := env.NewEnvironmentProvider(kubeClient)
+	EnvironmentProvider.OnLookupFailure = func(secretName string, err error) {
+		serviceMetrics.SecretLookupFailed()
+	}
+	environment := []string{}
+	if err != nil {
+		// e.g., when running outside of the cluster
+		log.Printf("Could not create Kubernetes client, no secret is passed to locust: %s", err.Error())
+	} else {
+		environment = EnvironmentProvider.PrepareEnvironment(myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService())
+	}
+	log.Println(r.describe("Running locust tests"))
+	serviceMetrics.RunStarted(myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService())
+
+	// report the progress of long running tests via status.changed events
+	progressReporter := progress.NewReporter(r.csvPrefix, progressInterval, func(p progress.Progress) {
+		_, err := myKeptn.SendTaskStatusChangedEvent(&keptnv2.EventData{
+			Message: r.describe(p.String()),
+		}, ServiceName)
+		if err != nil {
+			log.Printf("Could not send status changed event: %s", err.Error())
+		}
+	})
+	// stop locust if it takes longer than the workload (or the service) allows
+	runCtx := ctx
+	timeout := getTimeout(r.workload, r.runTime)
+	if timeout > 0 {
+		var cancelRun context.CancelFunc
+		runCtx, cancelRun = context.WithTimeout(ctx, timeout)
+		defer cancelRun()
+	}
+
+	// the output is logged line by line while locust is running, only its end is kept in memory
+	output := logstream.NewLineWriter(func(line string) {
+		log.Printf("[%s] %s", myKeptn.KeptnContext, r.describe(line))
+	})
+
+	run := r.newRecord(myKeptn, data)
+	run.Start = startTime
+	run.Status = RunStatusRunning
+	recordRun(run)
+
+	progressReporter.Start()
+	locustOutput, err := runner.Run(runCtx, locustRunner, runner.Spec{
+		ID:          r.id,
+		Command:     r.locust,
+		Args:        r.command,
+		WorkerArgs:  r.workerCommand,
+		Workers:     getWorkers(r.workload),
+		Env:         environment,
+		WorkDir:     r.dir,
+		ResultFiles: locustResultFiles(r.index),
+		Namespace:   EnvironmentProvider.KeptnNamespaceProvider(),
+		SecretName:  EnvironmentProvider.SecretName(myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService()),
+		Labels: map[string]string{
+			"app.kubernetes.io/managed-by": ServiceName,
+			"keptn.sh/context":             myKeptn.KeptnContext,
+		},
+		Output: output,
+	})
+	progressReporter.Stop()
+	output.Flush()
+
+	log.Println(r.describe("Finished running locust tests"))
+
+	run.ExitCode = exitCode(err)
+	// the end of the output is kept for every run, the warnings of a successful run can be worth a look as well
+	run.Output = locustOutput
+
+	if err != nil && runCtx.Err() == nil && run.ExitCode == 1 && r.wroteStatistics() {
+		// locust exits with 1 as soon as a single request has failed (--exit-code-on-error), such a run has been
+		// completed and its thresholds decide about the result
+		log.Println(r.describe("Locust reported failed requests"))
+		err = nil
+	}
+
+	if ctx.Err() != nil {
+		log.Printf("Locust test %s has been aborted", r.id)
+		run.End = time.Now()
+		run.Status = RunStatusAborted
+		run.Result = string(keptnv2.ResultFailed)
+		run.Message = AbortedMessage
+		recordRun(run)
+		serviceMetrics.RunAborted(run.Project, run.Stage, run.Service, run.End.Sub(run.Start))
+
+		workloadResult.Status = keptnv2.StatusErrored
+		workloadResult.Result = keptnv2.ResultFailed
+		workloadResult.Message = AbortedMessage
+		return workloadResult
+	}
+
+	if runCtx.Err() == context.DeadlineExceeded {
+		message := fmt.Sprintf("Locust test timed out after %s", timeout)
+		log.Println(r.describe(message))
+
+		// locust writes the statistics collected so far when it is terminated
+		statistics, err := r.parseStatistics(myKeptn)
+		if err == nil {
+			workloadResult.Locust = statistics.Summary()
+		}
+
+		r.writeJUnitReport(myKeptn, data, tempDir, startTime, statistics, nil, message)
+		r.uploadReport(myKeptn, workloadResult)
+
+		run.End = time.Now()
+		run.Status = string(keptnv2.StatusErrored)
+		run.Result = string(keptnv2.ResultFailed)
+		run.Message = message
+		run.Statistics = statistics
+		recordRun(run)
+		serviceMetrics.RunErrored(run.Project, run.Stage, run.Service, run.End.Sub(run.Start))
+
+		workloadResult.Status = keptnv2.StatusErrored
+		workloadResult.Result = keptnv2.ResultFailed
+		workloadResult.Message = message
+		workloadResult.err = errors.New(message)
+		return workloadResult
+	}
+
+	if err != nil {
+		// report error
+		log.Print(err)
+		// locust may have written the statistics and the report before it failed, they are needed the most to debug
+		// the failure
+		statistics, parseErr := r.parseStatistics(myKeptn)
+		if parseErr == nil {
+			workloadResult.Locust = statistics.Summary()
+		}
+		r.writeJUnitReport(myKeptn, data, tempDir, startTime, statistics, nil, err.Error())
+		r.uploadReport(myKeptn, workloadResult)
+
+		run.End = time.Now()
+		run.Status = string(keptnv2.StatusErrored)
+		run.Result = string(keptnv2.ResultFailed)
+		run.Message = err.Error()
+		run.Statistics = statistics
+		recordRun(run)
+		serviceMetrics.RunErrored(run.Project, run.Stage, run.Service, run.End.Sub(run.Start))
+
+		workloadResult.Status = keptnv2.StatusErrored
+		workloadResult.Result = keptnv2.ResultFailed
+		workloadResult.Message = err.Error()
+		workloadResult.err = err
+		return workloadResult
+	}
+
+	statistics, err := r.parseStatistics(myKeptn)
+	if err == nil {
+		workloadResult.Locust = statistics.Summary()
+		serviceMetrics.RecordStatistics(myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService(), statistics)
+	}
+
+	result := keptnv2.ResultPass
+	message := workloadResult.Message
+	if statistics != nil && statistics.Aggregated.FailureCount > 0 {
+		message = fmt.Sprintf("Locust test finished, %d of %d requests failed", statistics.Aggregated.FailureCount, statistics.Aggregated.RequestCount)
+	}
+	if r.workload != nil && len(r.workload.Thresholds) > 0 {
+		result, message, workloadResult.Violations = evaluateThresholds(r.workload.Thresholds, statistics)
+	}
+
+	if r.workload != nil && r.workload.Baseline != nil && statistics != nil {
+		comparison, err := compareWithBaseline(r.workload.Baseline, myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService(), data.Test.TestStrategy, r.workloadName(), statistics)
+		if err != nil {
+			log.Printf("Could not compare run with baseline: %s", err.Error())
+		} else {
+			workloadResult.Baseline = comparison
+			result = worstResult(result, resultForSeverity(comparison.Severity))
+			message = message + "\n" + comparison.String()
+		}
+	}
+
+	r.writeJUnitReport(myKeptn, data, tempDir, startTime, statistics, workloadResult.Violations, "")
+	r.uploadReport(myKeptn, workloadResult)
+
+	run.End = time.Now()
+	run.Status = string(keptnv2.StatusSucceeded)
+	run.Result = string(result)
+	run.Message = message
+	run.Statistics = statistics
+	recordRun(run)
+	serviceMetrics.RunFinished(run.Project, run.Stage, run.Service, run.Result, run.End.Sub(run.Start))
+
+	workloadResult.Result = result
+	workloadResult.Message = message
+	return workloadResult
+}
+
+// workloadName returns the name the workload is recorded with in the history, which also selects its baseline.
+// Unnamed workloads sharing the test strategy with others are recorded with their display name (e.g. their script),
+// so they are not compared with each other. A single unnamed workload keeps an empty name.
+func (r *workloadRun) workloadName() string {
+	if r.workload == nil {
+		return ""
+	}
+	if r.workload.Name == "" && r.shared {
+		return r.name
+	}
+	return r.workload.Name
+}
+
+// uploadReport uploads the HTML report of the run, if locust has written one, and links it in the result of the
+// workload. Scheduled runs would add a report to the config repo every time they run, so they are skipped.
+func (r *workloadRun) uploadReport(myKeptn *keptnv2.Keptn, workloadResult *WorkloadResult) {
+	if r.schedule != "" {
+		return
+	}
+	if _, err := os.Stat(r.reportFile); err != nil {
+		// e.g. locust could not be started at all
+		log.Printf("No HTML report found: %s", err.Error())
+		return
+	}
+
+	reportURI, err := uploadHTMLReport(myKeptn, r.reportFile, workloadFilename(myKeptn.KeptnContext+".html", r.index))
+	if err != nil {
+		log.Printf("Could not upload HTML report: %s", err.Error())
+		return
+	}
+	workloadResult.Report = reportURI
+	workloadResult.labels = map[string]string{workloadFilename(LocustReportLabel, r.index): reportURI}
+}
+
+// writeJUnitReport writes the JUnit report of the run, runError is reported if the run has not completed
+func (r *workloadRun) writeJUnitReport(myKeptn *keptnv2.Keptn, data *keptnv2.TestTriggeredEventData, tempDir string, startTime time.Time, statistics *stats.Statistics, violations []thresholds.Violation, runError string) {
+	suiteName := data.Test.TestStrategy
+	if r.shared {
+		suiteName = fmt.Sprintf("%s %s", data.Test.TestStrategy, r.name)
+	}
+	err := writeJUnitReport(resultKey(myKeptn), tempDir, workloadFilename(junit.Filename, r.index), suiteName, startTime, statistics, violations, runError)
+	if err != nil {
+		log.Printf("Could not write JUnit report: %s", err.Error())
+	}
+}
+
+// wroteStatistics returns true if locust has written the CSV statistics of the run
+func (r *workloadRun) wroteStatistics() bool {
+	_, err := os.Stat(r.csvPrefix + "_stats.csv")
+	return err == nil
+}
+
+// parseStatistics parses the CSV statistics of the run. The statistics of the first workload are stored in the
+// result store, they answer the get-sli events of the keptnContext, stage and service.
+func (r *workloadRun) parseStatistics(myKeptn *keptnv2.Keptn) (*stats.Statistics, error) {
+	statistics, err := stats.ParseStats(r.csvPrefix)
+	if err != nil {
+		log.Printf("Could not parse locust statistics: %s", err.Error())
+		return nil, err
+	}
+
+	if r.index == 0 {
+		if err := resultStore.SaveStatistics(resultKey(myKeptn), statistics); err != nil {
+			log.Printf("Could not store locust statistics: %s", err.Error())
+		}
+	}
+	return statistics, nil
+}
+
+// aggregateResults combines the results of the workloads of a test. A single workload is reported as is, otherwise
+// the test gets the worst result of the workloads and errors if any of them has errored.
+func aggregateResults(results []*WorkloadResult) (keptnv2.StatusType, keptnv2.ResultType, string) {
+	if len(results) == 1 {
+		return results[0].Status, results[0].Result, results[0].Message
+	}
+
+	status := keptnv2.StatusSucceeded
+	result := keptnv2.ResultPass
+	lines := []string{}
+	for _, workloadResult := range results {
+		if workloadResult.Status == keptnv2.StatusErrored {
+			status = keptnv2.StatusErrored
+		}
+		result = worstResult(result, workloadResult.Result)
+		// the details of the workloads are part of the per-workload results
+		summary := strings.SplitN(workloadResult.Message, "\n", 2)[0]
+		lines = append(lines, fmt.Sprintf("- %s: %s (%s)", workloadResult.Name, workloadResult.Result, summary))
+	}
+
+	header := fmt.Sprintf("Locust test finished %d workloads with result %s:", len(results), result)
+	return status, result, strings.Join(append([]string{header}, lines...), "\n")
+}
+
+// HandleGetSliTriggeredEvent handles get-sli.triggered events by computing the requested SLIs from the statistics of
+// the locust run with the same keptnContext, stage and service
+func HandleGetSliTriggeredEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.GetSLITriggeredEventData) error {
+	log.Printf("Handling get-sli.triggered Event: %s", incomingEvent.Context.GetID())
+
+	// Only handle get-sli events that are meant for this SLI provider
+	if data.GetSLI.SLIProvider != SliProviderName {
+		log.Printf("Not handling get-sli event as it is meant for %s", data.GetSLI.SLIProvider)
+		return nil
+	}
+
+	_, err := myKeptn.SendTaskStartedEvent(&keptnv2.EventData{}, ServiceName)
+	if err != nil {
+		log.Printf("Failed to send task started CloudEvent (%s), aborting... \n", err.Error())
+		return err
+	}
+
+	sliConfig, err := myKeptn.GetSLIConfiguration(myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService(), SliFilename)
+	if err != nil {
+		return sendGetSliErroredEvent(myKeptn, data, fmt.Sprintf("Failed to load %s: %s", SliFilename, err.Error()))
+	}
+
+	// a keptnContext spans all stages of the sequence, a stage without locust test has no statistics
+	key := resultKey(myKeptn)
+	statistics, err := resultStore.LoadStatistics(key)
+	if err != nil {
+		return sendGetSliErroredEvent(myKeptn, data, fmt.Sprintf("No locust statistics found for keptnContext %s in stage %s of service %s: %s", key.KeptnContext, key.Stage, key.Service, err.Error()))
+	}
+
+	indicatorValues := []*keptnv2.SLIResult{}
+	for _, indicator := range data.GetSLI.Indicators {
+		// indicators without a query in sli.yaml are interpreted as query themselves
+		query, ok := sliConfig[indicator]
+		if !ok {
+			query = indicator
+		}
+
+		value, err := getSLIValue(query, statistics)
+		if err != nil {
+			log.Printf("Failed to compute SLI %s: %s", indicator, err.Error())
+			indicatorValues = append(indicatorValues, &keptnv2.SLIResult{
+				Metric:  indicator,
+				Success: false,
+				Message: err.Error(),
+			})
+			continue
+		}
+
+		indicatorValues = append(indicatorValues, &keptnv2.SLIResult{
+			Metric:  indicator,
+			Value:   value,
+			Success: true,
+		})
+	}
+
+	finishedEvent := &keptnv2.GetSLIFinishedEventData{
+		EventData: keptnv2.EventData{
+			Status: keptnv2.StatusSucceeded,
+			Result: keptnv2.ResultPass,
+		},
+		GetSLI: keptnv2.GetSLIFinished{
+			Start:           data.GetSLI.Start,
+			End:             data.GetSLI.End,
+			IndicatorValues: indicatorValues,
+		},
+	}
+
+	_, err = myKeptn.SendTaskFinishedEvent(finishedEvent, ServiceName)
+	if err != nil {
+		log.Printf("Failed to send task finished CloudEvent (%s), aborting...\n", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// resultKey identifies the results of the event's test in the result store
+func resultKey(myKeptn *keptnv2.Keptn) results.Key {
+	return results.Key{
+		KeptnContext: myKeptn.KeptnContext,
+		Stage:        myKeptn.Event.GetStage(),
+		Service:      myKeptn.Event.GetService(),
+	}
+}
+
+// getSLIValue computes the value of a single SLI query against the statistics of a locust run
+func getSLIValue(query string, statistics *stats.Statistics) (float64, error) {
+	parsedQuery, err := sli.ParseQuery(query)
+	if err != nil {
+		return 0, err
+	}
+	return parsedQuery.Value(statistics)
+}
+
+// sendGetSliErroredEvent sends a get-sli.finished event with status=errored and result=failed back to Keptn
+func sendGetSliErroredEvent(myKeptn *keptnv2.Keptn, data *keptnv2.GetSLITriggeredEventData, message string) error {
+	log.Println(message)
+
+	_, err := myKeptn.SendTaskFinishedEvent(&keptnv2.GetSLIFinishedEventData{
+		EventData: keptnv2.EventData{
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Message: message,
+		},
+		GetSLI: keptnv2.GetSLIFinished{
+			Start: data.GetSLI.Start,
+			End:   data.GetSLI.End,
+		},
+	}, ServiceName)
+
+	return err
+}
+
+// evaluateThresholds checks the thresholds of a workload against the statistics of the locust run and
+// returns the resulting test result together with a message listing every violated threshold
+func evaluateThresholds(workloadThresholds []*thresholds.Threshold, statistics *stats.Statistics) (keptnv2.ResultType, string, []thresholds.Violation) {
+	if statistics == nil {
+		return keptnv2.ResultFailed, "Locust test finished, but thresholds could not be evaluated because no statistics are available", nil
+	}
+
+	violations := thresholds.Evaluate(workloadThresholds, statistics)
+	if len(violations) == 0 {
+		return keptnv2.ResultPass, "Locust test finished successfully, all thresholds have been met", violations
+	}
+
+	lines := []string{fmt.Sprintf("Locust test finished with %d violated threshold(s):", len(violations))}
+	for _, violation := range violations {
+		lines = append(lines, violation.String())
+	}
+	message := strings.Join(lines, "\n")
+
+	return resultForSeverity(thresholds.HighestSeverity(violations)), message, violations
+}
+
+// compareWithBaseline compares the statistics of a run with the last successful runs of the same project, stage,
+// service, test strategy and workload name in the run history. Scheduled runs are not used as baseline, they run
+// against whatever is deployed and not as quality gate of a deployment.
+func compareWithBaseline(config *baseline.Config, project string, stage string, service string, testStrategy string, workloadName string, statistics *stats.Statistics) (*baseline.Comparison, error) {
+	if runHistory == nil {
+		return nil, errors.New("run history is not available")
+	}
+
+	runs, err := runHistory.List(history.Filter{
+		Project:      project,
+		Stage:        stage,
+		Service:      service,
+		TestStrategy: testStrategy,
+		WorkloadName: workloadName,
+		Result:       string(keptnv2.ResultPass),
+		Limit:        config.RunCount(),
+
+		ExcludeScheduled: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	runIDs := []string{}
+	baselines := []*stats.Statistics{}
+	for _, run := range runs {
+		if run.Statistics != nil {
+			runIDs = append(runIDs, run.ID)
+			baselines = append(baselines, run.Statistics)
+		}
+	}
+
+	return baseline.Compare(config, statistics, runIDs, baselines), nil
+}
+
+// resultForSeverity maps the severity of violated thresholds or regressions to the result of the test
+func resultForSeverity(severity thresholds.Severity) keptnv2.ResultType {
+	switch severity {
+	case thresholds.SeverityFail:
+		return keptnv2.ResultFailed
+	case thresholds.SeverityWarning:
+		return keptnv2.ResultWarning
+	default:
+		return keptnv2.ResultPass
+	}
+}
+
+// worstResult returns the worse of two test results
+func worstResult(a keptnv2.ResultType, b keptnv2.ResultType) keptnv2.ResultType {
+	if a == keptnv2.ResultFailed || b == keptnv2.ResultFailed {
+		return keptnv2.ResultFailed
+	}
+	if a == keptnv2.ResultWarning || b == keptnv2.ResultWarning {
+		return keptnv2.ResultWarning
+	}
+	return keptnv2.ResultPass
+}
+
+// sendTestAbortedEvent finishes a canceled test, so the sequence does not wait for it
+func sendTestAbortedEvent(myKeptn *keptnv2.Keptn, startTime time.Time) error {
+	_, err := myKeptn.SendTaskFinishedEvent(&keptnv2.TestFinishedEventData{
+		Test: keptnv2.TestFinishedDetails{
+			Start: startTime.Format(time.RFC3339),
+			End:   time.Now().Format(time.RFC3339),
+		},
+		EventData: keptnv2.EventData{
+			Status:  keptnv2.StatusErrored,
+			Result:  keptnv2.ResultFailed,
+			Message: AbortedMessage,
+		},
+	}, ServiceName)
+	if err != nil {
+		log.Printf("Failed to send task finished CloudEvent (%s), aborting...\n", err.Error())
+	}
+	return err
+}
+
+// HandleSequenceAbortedEvent cancels every queued or running test of the aborted sequence
+func HandleSequenceAbortedEvent(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event) error {
+	canceled := runRegistry.CancelKeptnContext(myKeptn.KeptnContext)
+	log.Printf("Sequence %s has been aborted, canceled %d locust tests", myKeptn.KeptnContext, canceled)
+	return nil
+}
+
+// locustResultFiles returns the files locust writes into the workload directory (relative to it) for the workload
+// with the given index
+func locustResultFiles(index int) []string {
+	csvPrefix := workloadFilename(LocustCSVPrefix, index)
+	return []string{
+		csvPrefix + "_stats.csv",
+		csvPrefix + "_failures.csv",
+		csvPrefix + "_stats_history.csv",
+		workloadFilename(LocustReportFilename, index),
+	}
+}
+
+// getTimeout returns the timeout of the workload, limited by the service-wide maximum (0 means no timeout).
+// Workloads without timeout may run for the expected run time plus their stop_timeout and the timeoutMargin,
+// the run time has been checked against the maximum before. Without run time the maximum applies.
+func getTimeout(workload *Workload, runTime time.Duration) time.Duration {
+	if workload != nil && workload.Timeout != "" {
+		// the timeout has been validated by parseLocustConf
+		timeout, _ := time.ParseDuration(workload.Timeout)
+		if maxTimeout > 0 && timeout > maxTimeout {
+			return maxTimeout
+		}
+		return timeout
+	}
+	if runTime <= 0 {
+		return maxTimeout
+	}
+	timeout := runTime + timeoutMargin
+	if workload != nil && workload.StopTimeout != "" {
+		timeout += timespanDuration(workload.StopTimeout)
+	}
+	return timeout
+}
+
+// getWorkers returns the number of locust workers for the workload, falling back to the service-wide default
+func getWorkers(workload *Workload) int {
+	if workload != nil && workload.Workers != nil {
+		return *workload.Workers
+	}
+	return defaultWorkers
+}
+
+// newRecord creates the record of the run in the run history
+func (r *workloadRun) newRecord(myKeptn *keptnv2.Keptn, data *keptnv2.TestTriggeredEventData) *history.Run {
+	return &history.Run{
+		ID:           r.id,
+		KeptnContext: myKeptn.KeptnContext,
+		TriggeredID:  myKeptn.CloudEvent.ID(),
+		Project:      myKeptn.Event.GetProject(),
+		Stage:        myKeptn.Event.GetStage(),
+		Service:      myKeptn.Event.GetService(),
+		TestStrategy: data.Test.TestStrategy,
+		WorkloadName: r.workloadName(),
+		Schedule:     r.schedule,
+		Workload:     r.workload,
+		Arguments:    r.command,
+	}
+}
+
+// recordQueuedTest records a test that waits for a worker in the run history. Until its workloads are known, the
+// test is recorded with the ID of the test.triggered event.
+func recordQueuedTest(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.TestTriggeredEventData, schedule string) {
+	recordRun(&history.Run{
+		ID:           incomingEvent.ID(),
+		KeptnContext: myKeptn.KeptnContext,
+		TriggeredID:  incomingEvent.ID(),
+		Project:      myKeptn.Event.GetProject(),
+		Stage:        myKeptn.Event.GetStage(),
+		Service:      myKeptn.Event.GetService(),
+		TestStrategy: data.Test.TestStrategy,
+		Schedule:     schedule,
+		Start:        time.Now(),
+		Status:       RunStatusQueued,
+	})
+}
+
+// recordQueuedRuns replaces the queued test in the run history by its workload runs, which are queued until they
+// are executed
+func recordQueuedRuns(myKeptn *keptnv2.Keptn, incomingEvent cloudevents.Event, data *keptnv2.TestTriggeredEventData, runs []*workloadRun) {
+	if runHistory == nil {
+		return
+	}
+
+	replaced := false
+	for _, r := range runs {
+		if r.skip {
+			continue
+		}
+		run := r.newRecord(myKeptn, data)
+		run.Start = time.Now()
+		run.Status = RunStatusQueued
+		recordRun(run)
+		replaced = replaced || r.id == incomingEvent.ID()
+	}
+	if !replaced {
+		if err := runHistory.Delete(incomingEvent.ID()); err != nil {
+			log.Printf("Could not remove queued test %s from history: %s", incomingEvent.ID(), err.Error())
+		}
+	}
+}
+
+// finishQueuedRuns updates the runs of a test that are still queued once the test has been handled, they have not
+// been started because the test has been canceled or failed before. If the test has neither been canceled nor
+// failed, nothing has been run and the records are removed.
+func finishQueuedRuns(ctx context.Context, keptnContext string, triggeredID string, err error) {
+	if runHistory == nil {
+		return
+	}
+
+	runs, listErr := runHistory.List(history.Filter{KeptnContext: keptnContext})
+	if listErr != nil {
+		log.Printf("Could not read queued runs of %s from history: %s", triggeredID, listErr.Error())
+		return
+	}
+	for _, run := range runs {
+		if run.TriggeredID != triggeredID || run.Status != RunStatusQueued {
+			continue
+		}
+		switch {
+		case ctx.Err() != nil:
+			run.Status = RunStatusAborted
+			run.Message = AbortedMessage
+		case err != nil:
+			run.Status = string(keptnv2.StatusErrored)
+			run.Message = err.Error()
+		default:
+			if err := runHistory.Delete(run.ID); err != nil {
+				log.Printf("Could not remove queued run %s from history: %s", run.ID, err.Error())
+			}
+			continue
+		}
+		run.End = time.Now()
+		run.Result = string(keptnv2.ResultFailed)
+		recordRun(run)
+	}
+}
+
+// cancelTest cancels the queued or running test the run with the given ID belongs to. Runs are registered with the
+// ID of the test.triggered event, the runs of a test with several workloads are resolved through the run history.
+func cancelTest(id string) bool {
+	if runRegistry.Cancel(id) {
+		return true
+	}
+	if runHistory == nil {
+		return false
+	}
+	run, err := runHistory.Get(id)
+	if err != nil || run.TriggeredID == "" || run.TriggeredID == id {
+		return false
+	}
+	return runRegistry.Cancel(run.TriggeredID)
+}
+
+// recordRun stores the run in the run history, if the history is available
+func recordRun(run *history.Run) {
+	if runHistory == nil {
+		return
+	}
+	if err := runHistory.Save(run); err != nil {
+		log.Printf("Could not record run %s in history: %s", run.ID, err.Error())
+	}
+}
+
+// exitCode returns the exit code of locust from the error of the runner.Runner (of the local process or of the
+// Kubernetes job), 0 without error and -1 if locust has not exited with a code, e.g. when it could not be started
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	var jobErr *kubejob.JobFailedError
+	if errors.As(err, &jobErr) {
+		return jobErr.ExitCode
+	}
+	return -1
+}
+
+// writeJUnitReport generates the JUnit XML report of a locust run into the given file of the temp directory and
+// stores it in the result store, so it can be retrieved by keptnContext, stage and service
+func writeJUnitReport(key results.Key, tempDir string, filename string, name string, startTime time.Time, statistics *stats.Statistics, violations []thresholds.Violation, runError string) error {
+	if name == "" {
+		name = ServiceName
+	}
+
+	report, err := junit.Generate(name, startTime, time.Since(startTime), statistics, violations, runError)
+	if err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(fmt.Sprintf("%s/%s", tempDir, filename), report, 0644); err != nil {
+		return err
+	}
+
+	return resultStore.SaveArtifact(key, filename, report)
+}
+
+// uploadHTMLReport stores the HTML report of a locust run in the result store and uploads it with the given name
+// to the config repo of the current service and stage. It returns the URI of the uploaded resource.
+func uploadHTMLReport(myKeptn *keptnv2.Keptn, reportFile string, name string) (string, error) {
+	content, err := ioutil.ReadFile(reportFile)
+	if err != nil {
+		return "", err
+	}
+
+	if err := resultStore.SaveArtifact(resultKey(myKeptn), filepath.Base(reportFile), content); err != nil {
+		log.Printf("Could not store HTML report: %s", err.Error())
+	}
+
+	if myKeptn.UseLocalFileSystem {
+		return "", errors.New("uploading resources is not supported when running with the local filesystem")
+	}
+
+	resourceURI := fmt.Sprintf("%s/%s", LocustReportsDir, name)
+	_, err = myKeptn.ResourceHandler.CreateServiceResources(myKeptn.Event.GetProject(), myKeptn.Event.GetStage(), myKeptn.Event.GetService(), []*models.Resource{
+		{
+			ResourceURI:     &resourceURI,
+			ResourceContent: string(content),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Uploaded HTML report to %s", resourceURI)
+	return resourceURI, nil
 }
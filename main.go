@@ -2,19 +2,91 @@ package main
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	cloudevents "github.com/cloudevents/sdk-go/v2" // make sure to use v2 cloudevents here
 	"github.com/kelseyhightower/envconfig"
 	keptn "github.com/keptn/go-utils/pkg/lib/keptn"
 	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+	k8sutils "github.com/keptn/kubernetes-utils/pkg"
+
+	"github.com/keptn-sandbox/locust-service/pkg/history"
+	"github.com/keptn-sandbox/locust-service/pkg/httpjson"
+	"github.com/keptn-sandbox/locust-service/pkg/kubejob"
+	"github.com/keptn-sandbox/locust-service/pkg/logstream"
+	"github.com/keptn-sandbox/locust-service/pkg/metrics"
+	"github.com/keptn-sandbox/locust-service/pkg/registry"
+	"github.com/keptn-sandbox/locust-service/pkg/results"
+	"github.com/keptn-sandbox/locust-service/pkg/runner"
+	"github.com/keptn-sandbox/locust-service/pkg/targetlock"
+	"github.com/keptn-sandbox/locust-service/pkg/virtualenv"
+	"github.com/keptn-sandbox/locust-service/pkg/workerpool"
+	"github.com/keptn-sandbox/locust-service/pkg/workspace"
 )
 
 var keptnOptions = keptn.KeptnOpts{}
 
+// resultStore keeps the results of the locust runs so they can be used for later events of the same keptnContext
+var resultStore = results.NewStore(filepath.Join(os.TempDir(), "locust-service", "results"))
+
+// serviceMetrics holds the Prometheus metrics of the service
+var serviceMetrics = metrics.New()
+
+// progressInterval defines how often the progress of a running locust test is reported
+var progressInterval = time.Minute
+
+// maxTimeout limits how long a locust test may run (0 means no limit)
+var maxTimeout = 2 * time.Hour
+
+// timeoutMargin is added to the run time of workloads without timeout, locust needs some time to start and stop
+var timeoutMargin = 5 * time.Minute
+
+// loadProfile holds the defaults and limits of the load parameters
+var loadProfile = LoadProfile{DefaultUsers: 10, DefaultRunTime: "2m"}
+
+// defaultWorkers defines the number of locust workers for workloads that do not configure it (0 runs locust in a single process)
+var defaultWorkers = 0
+
+// locustRunner executes the locust runs, either within the service or as Kubernetes jobs
+var locustRunner runner.Runner = runner.NewLocal(30 * time.Second)
+
+// jobResults receives the result files of the Kubernetes jobs, it is nil unless locust runs as Kubernetes job
+var jobResults *kubejob.Receiver
+
+// targetLocks keeps tests from running against the same target at the same time
+var targetLocks = targetlock.New()
+
+// targetKeyMode defines which tests run against the same target: TargetKeyService or TargetKeyHost
+var targetKeyMode = TargetKeyService
+
+// workspaces creates the directories the tests work in and removes them according to the cleanup policy
+var workspaces = workspace.NewManager(filepath.Join(os.TempDir(), "locust-service", "workspaces"), workspace.Policy{Cleanup: workspace.CleanupAlways})
+
+// virtualenvs holds the virtualenvs with the Python requirements of the locustfiles, nil disables them
+var virtualenvs *virtualenv.Manager
+
+// testQueue runs the locust tests in the background, so test.triggered events can be acknowledged immediately. It is
+// created in _main with the configured number of workers.
+var testQueue *workerpool.Pool
+
+// scheduleQueue runs the scheduled tests, so they neither delay nor reject the tests triggered by Keptn. It is
+// created in _main if any services are scheduled.
+var scheduleQueue *workerpool.Pool
+
+// runRegistry keeps track of the queued and running tests, so they can be canceled
+var runRegistry = registry.New()
+
+// runHistory records every locust run, it is opened in _main and the service does not start without it
+var runHistory *history.Store
+
 type envConfig struct {
 	// Port on which to listen for cloudevents
 	Port int `envconfig:"RCV_PORT" default:"8080"`
@@ -24,8 +96,84 @@ type envConfig struct {
 	Env string `envconfig:"ENV" default:"local"`
 	// URL of the Keptn configuration service (this is where we can fetch files from the config repo)
 	ConfigurationServiceUrl string `envconfig:"CONFIGURATION_SERVICE" default:""`
+	// Directory in which the service stores the results and the history of the locust runs
+	DataDir string `envconfig:"DATA_DIR" default:"/tmp/locust-service"`
+	// Port on which the API (e.g., the run history) is served
+	APIPort int `envconfig:"API_PORT" default:"8090"`
+	// Token required as bearer token by the requests of the API changing state, e.g. canceling a run (empty: none)
+	APIToken string `envconfig:"API_TOKEN" default:""`
+	// Port on which the Prometheus metrics are served
+	MetricsPort int `envconfig:"METRICS_PORT" default:"9090"`
+	// Interval in which the progress of a running locust test is reported via status.changed events (0 disables it)
+	ProgressInterval time.Duration `envconfig:"PROGRESS_INTERVAL" default:"1m"`
+	// Maximum duration of a locust test, it also applies to workloads without timeout (0 means no limit)
+	MaxTimeout time.Duration `envconfig:"MAX_TIMEOUT" default:"2h"`
+	// Time locust has to shut down after SIGTERM (on timeout or cancellation) before it is killed
+	TerminationGracePeriod time.Duration `envconfig:"TERMINATION_GRACE_PERIOD" default:"30s"`
+	// Number of users for workloads without users and conf file
+	DefaultUsers int `envconfig:"DEFAULT_USERS" default:"10"`
+	// Users started per second for workloads without spawn rate and conf file (0 uses the default of locust)
+	DefaultSpawnRate float64 `envconfig:"DEFAULT_SPAWN_RATE" default:"0"`
+	// Run time (e.g., 2m) for workloads without run time and conf file
+	DefaultRunTime string `envconfig:"DEFAULT_RUN_TIME" default:"2m"`
+	// Maximum number of users of a test (0 means no limit)
+	MaxUsers int `envconfig:"MAX_USERS" default:"0"`
+	// Maximum run time of a test (0 means no limit)
+	MaxRunTime time.Duration `envconfig:"MAX_RUN_TIME" default:"0"`
+	// Number of locust workers for workloads that do not define it (0 runs locust in a single process)
+	DefaultWorkers int `envconfig:"DEFAULT_WORKERS" default:"0"`
+	// Where locust runs: "local" runs it within the service, "kubernetes" runs it as a Kubernetes job
+	Runner string `envconfig:"RUNNER" default:"local"`
+	// Image of the Kubernetes jobs running locust
+	JobImage string `envconfig:"JOB_IMAGE" default:"locustio/locust"`
+	// URL of the API of the service the Kubernetes jobs upload their results to (default: http://<POD_IP>:<API_PORT>)
+	JobResultsURL string `envconfig:"JOB_RESULTS_URL" default:""`
+	// IP of the pod of the service, set via the downward API
+	PodIP string `envconfig:"POD_IP" default:""`
+	// Number of locust tests running at the same time
+	WorkerPoolSize int `envconfig:"WORKER_POOL_SIZE" default:"1"`
+	// Number of tests waiting for a free worker, further test.triggered events are rejected
+	QueueSize int `envconfig:"QUEUE_SIZE" default:"10"`
+	// Number of virtualenvs with Python requirements that are cached (0 disables installing requirements)
+	MaxVirtualenvs int `envconfig:"MAX_VIRTUALENVS" default:"5"`
+	// Which tests run against the same target: "service" (same project, stage and service) or "host" (same resolved host)
+	TargetLockKey string `envconfig:"TARGET_LOCK_KEY" default:"service"`
+	// Directory holding the workspaces of the tests (default: the workspaces directory of DATA_DIR)
+	WorkspaceDir string `envconfig:"WORKSPACE_DIR" default:""`
+	// When the workspace of a test is removed: "always", "on-success" (failed tests are kept) or "never"
+	WorkspaceCleanup string `envconfig:"WORKSPACE_CLEANUP" default:"always"`
+	// Number of kept workspaces that are retained, older ones are removed (0 retains all)
+	WorkspaceKeepLast int `envconfig:"WORKSPACE_KEEP_LAST" default:"10"`
+	// Time after which kept workspaces are removed (0 retains them regardless of their age)
+	WorkspaceMaxAge time.Duration `envconfig:"WORKSPACE_MAX_AGE" default:"24h"`
+	// Interval in which the janitor removes the kept workspaces exceeding WORKSPACE_KEEP_LAST or WORKSPACE_MAX_AGE
+	WorkspaceJanitorInterval time.Duration `envconfig:"WORKSPACE_JANITOR_INTERVAL" default:"10m"`
+	// Time after which the results of a keptnContext (statistics and reports) are removed (0 retains them)
+	ResultsMaxAge time.Duration `envconfig:"RESULTS_MAX_AGE" default:"168h"`
+	// Time after which runs are removed from the run history, counted from their start (0 retains them)
+	HistoryMaxAge time.Duration `envconfig:"HISTORY_MAX_AGE" default:"2160h"`
+	// Services whose locust/schedule.yaml is run on schedule (project/stage/service, comma separated)
+	ScheduledServices []string `envconfig:"SCHEDULED_SERVICES" default:""`
+	// Interval in which the schedule.yaml of the scheduled services is reloaded
+	ScheduleReloadInterval time.Duration `envconfig:"SCHEDULE_RELOAD_INTERVAL" default:"5m"`
+	// Number of scheduled tests running at the same time, in addition to the WORKER_POOL_SIZE triggered tests
+	ScheduleWorkerPoolSize int `envconfig:"SCHEDULE_WORKER_POOL_SIZE" default:"1"`
+	// Number of scheduled tests waiting for a free worker, further scheduled tests are skipped
+	ScheduleQueueSize int `envconfig:"SCHEDULE_QUEUE_SIZE" default:"10"`
+	// Size of the end of the locust output (in KB) that is kept for the results and error messages of a test
+	OutputBufferSize int `envconfig:"OUTPUT_BUFFER_SIZE" default:"64"`
 }
 
+const (
+	// RunnerLocal runs locust within the service container
+	RunnerLocal = "local"
+	// RunnerKubernetes runs locust as a Kubernetes job per test
+	RunnerKubernetes = "kubernetes"
+)
+
+// SequenceAbortedEventType is sent by Keptn when a sequence is aborted
+const SequenceAbortedEventType = "sh.keptn.event.sequence.aborted"
+
 // ServiceName specifies the current services name (e.g., used as source when sending CloudEvents)
 const ServiceName = "locust-service"
 
@@ -70,7 +218,24 @@ func processKeptnCloudEvent(ctx context.Context, event cloudevents.Event) error
 		eventData := &keptnv2.TestTriggeredEventData{}
 		parseKeptnCloudEventPayload(event, eventData)
 
-		return HandleTestTriggeredEvent(myKeptn, event, eventData)
+		return queueTestTriggeredEvent(myKeptn, event, eventData)
+
+	// -------------------------------------------------------
+	// sh.keptn.event.get-sli
+	case keptnv2.GetTriggeredEventType(keptnv2.GetSLITaskName): // sh.keptn.event.get-sli.triggered
+		log.Printf("Processing Get-SLI.Triggered Event")
+
+		eventData := &keptnv2.GetSLITriggeredEventData{}
+		parseKeptnCloudEventPayload(event, eventData)
+
+		return HandleGetSliTriggeredEvent(myKeptn, event, eventData)
+
+	// -------------------------------------------------------
+	// sh.keptn.event.sequence.aborted
+	case SequenceAbortedEventType:
+		log.Printf("Processing Sequence.Aborted Event")
+
+		return HandleSequenceAbortedEvent(myKeptn, event)
 	}
 
 	// Unknown Event -> Throw Error!
@@ -97,6 +262,58 @@ func main() {
 	os.Exit(_main(os.Args[1:], env))
 }
 
+/**
+ * Opens up a listener on localhost:apiPort serving the API of the locust-service
+ */
+func startAPIServer(port int, token string) {
+	if token == "" {
+		log.Println("No API_TOKEN configured, everyone reaching the API can cancel runs and remove workspaces")
+	}
+
+	mux := http.NewServeMux()
+	historyHandler := history.NewHandler(runHistory)
+	historyHandler.Cancel = cancelTest
+	mux.Handle(history.RunsPath, requireToken(token, historyHandler))
+	mux.Handle(history.RunsPath+"/", requireToken(token, historyHandler))
+	workspaceHandler := workspace.NewHandler(workspaces)
+	mux.Handle(workspace.WorkspacesPath, requireToken(token, workspaceHandler))
+	mux.Handle(workspace.WorkspacesPath+"/", requireToken(token, workspaceHandler))
+	mux.Handle(results.ReportsPath+"/", results.NewHandler(resultStore))
+	if jobResults != nil {
+		mux.Handle(kubejob.ResultsPath, jobResults)
+	}
+
+	log.Printf("Starting API server on Port = %d", port)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), mux))
+}
+
+// requireToken lets only the requests with the token as bearer token pass, except for GET requests. Without token
+// every request passes. The uploads of the Kubernetes jobs are not wrapped, they are authenticated per job.
+func requireToken(token string, next http.Handler) http.Handler {
+	if token == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if r.Method != http.MethodGet && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			httpjson.Error(w, http.StatusUnauthorized, "missing or invalid API token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+/**
+ * Opens up a listener on localhost:metricsPort serving the Prometheus metrics
+ */
+func startMetricsServer(port int) {
+	mux := http.NewServeMux()
+	mux.Handle(metrics.Path, serviceMetrics.Handler())
+
+	log.Printf("Starting metrics server on Port = %d", port)
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), mux))
+}
+
 /**
  * Opens up a listener on localhost:port/path and passes incoming requets to gotEvent
  */
@@ -109,6 +326,111 @@ func _main(args []string, env envConfig) int {
 
 	keptnOptions.ConfigurationServiceURL = env.ConfigurationServiceUrl
 
+	resultStore = results.NewStore(filepath.Join(env.DataDir, "results"))
+	resultStore.MaxAge = env.ResultsMaxAge
+	progressInterval = env.ProgressInterval
+	defaultWorkers = env.DefaultWorkers
+	maxTimeout = env.MaxTimeout
+	if env.DefaultRunTime != "" && !isLocustTimespan(env.DefaultRunTime) {
+		log.Fatalf("invalid DEFAULT_RUN_TIME %s", env.DefaultRunTime)
+	}
+	loadProfile = LoadProfile{
+		DefaultUsers:     env.DefaultUsers,
+		DefaultSpawnRate: env.DefaultSpawnRate,
+		DefaultRunTime:   env.DefaultRunTime,
+		MaxUsers:         env.MaxUsers,
+		MaxRunTime:       env.MaxRunTime,
+	}
+	testQueue = workerpool.NewPool(env.WorkerPoolSize, env.QueueSize)
+	defer testQueue.Close()
+
+	if env.TargetLockKey != TargetKeyService && env.TargetLockKey != TargetKeyHost {
+		log.Fatalf("invalid TARGET_LOCK_KEY %s, must be %s or %s", env.TargetLockKey, TargetKeyService, TargetKeyHost)
+	}
+	targetKeyMode = env.TargetLockKey
+
+	workspaceDir := env.WorkspaceDir
+	if workspaceDir == "" {
+		workspaceDir = filepath.Join(env.DataDir, "workspaces")
+	}
+	workspacePolicy := workspace.Policy{
+		Cleanup:  env.WorkspaceCleanup,
+		KeepLast: env.WorkspaceKeepLast,
+		MaxAge:   env.WorkspaceMaxAge,
+	}
+	if err := workspacePolicy.Validate(); err != nil {
+		log.Fatalf("invalid workspace policy: %v", err)
+	}
+	if env.WorkspaceJanitorInterval <= 0 {
+		log.Fatalf("invalid WORKSPACE_JANITOR_INTERVAL %s", env.WorkspaceJanitorInterval)
+	}
+	workspaces = workspace.NewManager(workspaceDir, workspacePolicy)
+	go workspaces.RunJanitor(context.Background(), env.WorkspaceJanitorInterval)
+	go resultStore.RunJanitor(context.Background(), env.WorkspaceJanitorInterval)
+
+	switch env.Runner {
+	case RunnerLocal:
+		local := runner.NewLocal(env.TerminationGracePeriod)
+		local.OutputLimit = env.OutputBufferSize * 1024
+		locustRunner = local
+
+		// the virtualenvs are only available to locust processes of the service itself
+		if env.MaxVirtualenvs > 0 {
+			virtualenvs = virtualenv.NewManager(filepath.Join(env.DataDir, "virtualenvs"), env.MaxVirtualenvs)
+			virtualenvs.Output = logstream.NewLineWriter(func(line string) {
+				log.Printf("[virtualenv] %s", line)
+			})
+		}
+	case RunnerKubernetes:
+		clientset, err := k8sutils.GetClientset(true)
+		if err != nil {
+			log.Fatalf("failed to create kubernetes client: %v", err)
+		}
+		resultsURL := env.JobResultsURL
+		if resultsURL == "" && env.PodIP != "" {
+			resultsURL = fmt.Sprintf("http://%s:%d", env.PodIP, env.APIPort)
+		}
+		if resultsURL == "" {
+			log.Fatalf("the kubernetes runner needs JOB_RESULTS_URL or POD_IP to receive the results of the jobs")
+		}
+		jobs := kubejob.NewRunner(clientset, env.JobImage, resultsURL)
+		jobs.GracePeriod = env.TerminationGracePeriod
+		jobResults = jobs.Receiver
+		kubernetes := runner.NewKubernetes(jobs)
+		kubernetes.OutputLimit = env.OutputBufferSize * 1024
+		locustRunner = kubernetes
+	default:
+		log.Fatalf("unknown runner %s, must be %s or %s", env.Runner, RunnerLocal, RunnerKubernetes)
+	}
+
+	if err := os.MkdirAll(env.DataDir, 0755); err != nil {
+		log.Fatalf("failed to create data directory %s: %v", env.DataDir, err)
+	}
+	var err error
+	runHistory, err = history.Open(filepath.Join(env.DataDir, "history.db"))
+	if err != nil {
+		log.Fatalf("failed to open run history: %v", err)
+	}
+	defer runHistory.Close()
+	runHistory.MaxAge = env.HistoryMaxAge
+	go runHistory.RunJanitor(context.Background(), env.WorkspaceJanitorInterval)
+
+	if len(env.ScheduledServices) > 0 {
+		services, err := parseScheduledServices(env.ScheduledServices)
+		if err != nil {
+			log.Fatalf("invalid SCHEDULED_SERVICES: %v", err)
+		}
+		if env.ScheduleReloadInterval <= 0 {
+			log.Fatalf("invalid SCHEDULE_RELOAD_INTERVAL %s", env.ScheduleReloadInterval)
+		}
+		scheduleQueue = workerpool.NewPool(env.ScheduleWorkerPoolSize, env.ScheduleQueueSize)
+		defer scheduleQueue.Close()
+		go startScheduler(context.Background(), services, env.ScheduleReloadInterval)
+	}
+
+	go startAPIServer(env.APIPort, env.APIToken)
+	go startMetricsServer(env.MetricsPort)
+
 	log.Println("Starting locust-service...")
 	log.Printf("    on Port = %d; Path=%s", env.Port, env.Path)
 
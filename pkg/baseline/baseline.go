@@ -0,0 +1,192 @@
+package baseline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/keptn-sandbox/locust-service/pkg/thresholds"
+)
+
+// DefaultRuns is the number of previous runs the baseline is computed from if nothing else is configured
+const DefaultRuns = 5
+
+// Config defines how a run is compared against the previous successful runs. Latency regressions are
+// relative to the baseline (in percent), failure rate regressions are absolute (in percentage points).
+type Config struct {
+	Runs           int      `json:"runs" yaml:"runs"`
+	WarningPercent *float64 `json:"warning_percent" yaml:"warning_percent"`
+	FailPercent    *float64 `json:"fail_percent" yaml:"fail_percent"`
+}
+
+// RunCount returns the number of previous runs the baseline is computed from
+func (c *Config) RunCount() int {
+	if c.Runs <= 0 {
+		return DefaultRuns
+	}
+	return c.Runs
+}
+
+// Validate checks that the configured regression percentages are usable
+func (c *Config) Validate() error {
+	if c.WarningPercent != nil && *c.WarningPercent < 0 {
+		return fmt.Errorf("warning_percent must not be negative")
+	}
+	if c.FailPercent != nil && *c.FailPercent < 0 {
+		return fmt.Errorf("fail_percent must not be negative")
+	}
+	if c.WarningPercent != nil && c.FailPercent != nil && *c.WarningPercent > *c.FailPercent {
+		return fmt.Errorf("warning_percent must not be greater than fail_percent")
+	}
+	return nil
+}
+
+// Delta compares a single metric of an endpoint with its baseline
+type Delta struct {
+	Metric   string              `json:"metric"`
+	Baseline float64             `json:"baseline"`
+	Current  float64             `json:"current"`
+	Percent  float64             `json:"percent"`
+	Severity thresholds.Severity `json:"severity,omitempty"`
+}
+
+// EndpointComparison holds the deltas of all compared metrics of an endpoint
+type EndpointComparison struct {
+	Endpoint string  `json:"endpoint"`
+	Deltas   []Delta `json:"deltas"`
+}
+
+// Comparison is the result of comparing a run against the previous successful runs
+type Comparison struct {
+	BaselineRuns []string             `json:"baselineRuns"`
+	Endpoints    []EndpointComparison `json:"endpoints,omitempty"`
+	Severity     thresholds.Severity  `json:"severity,omitempty"`
+}
+
+// latencyMetrics are compared relative to the baseline
+var latencyMetrics = []struct {
+	name  string
+	value func(r *stats.RequestStats) float64
+}{
+	{"p50", func(r *stats.RequestStats) float64 { return r.P50 }},
+	{"p95", func(r *stats.RequestStats) float64 { return r.P95 }},
+	{"p99", func(r *stats.RequestStats) float64 { return r.P99 }},
+}
+
+// Compare computes the per-endpoint deltas between the current statistics and the average of the baseline statistics
+func Compare(config *Config, current *stats.Statistics, baselineRuns []string, baselines []*stats.Statistics) *Comparison {
+	comparison := &Comparison{
+		BaselineRuns: baselineRuns,
+	}
+	if len(baselines) == 0 {
+		return comparison
+	}
+
+	endpoints := append([]stats.RequestStats{current.Aggregated}, current.Endpoints...)
+	for i := range endpoints {
+		requestStats := &endpoints[i]
+		name := endpointName(requestStats)
+
+		baselineStats := []*stats.RequestStats{}
+		for _, baseline := range baselines {
+			if endpoint := baseline.Endpoint(name); endpoint != nil {
+				baselineStats = append(baselineStats, endpoint)
+			}
+		}
+		if len(baselineStats) == 0 {
+			continue
+		}
+
+		endpointComparison := EndpointComparison{Endpoint: name}
+		for _, metric := range latencyMetrics {
+			baselineValue := average(baselineStats, metric.value)
+			delta := Delta{Metric: metric.name, Baseline: baselineValue, Current: metric.value(requestStats)}
+			if baselineValue > 0 {
+				delta.Percent = (delta.Current - baselineValue) / baselineValue * 100
+			}
+			delta.Severity = config.severity(delta.Percent)
+			endpointComparison.Deltas = append(endpointComparison.Deltas, delta)
+		}
+
+		failureRate := func(r *stats.RequestStats) float64 { return r.FailureRatio() * 100 }
+		delta := Delta{Metric: "failure_rate", Baseline: average(baselineStats, failureRate), Current: failureRate(requestStats)}
+		delta.Percent = delta.Current - delta.Baseline
+		delta.Severity = config.severity(delta.Percent)
+		endpointComparison.Deltas = append(endpointComparison.Deltas, delta)
+
+		for _, delta := range endpointComparison.Deltas {
+			comparison.Severity = worstSeverity(comparison.Severity, delta.Severity)
+		}
+		comparison.Endpoints = append(comparison.Endpoints, endpointComparison)
+	}
+	return comparison
+}
+
+// Regressions returns a human readable line for every delta that exceeds the configured regression percentages
+func (c *Comparison) Regressions() []string {
+	lines := []string{}
+	for _, endpoint := range c.Endpoints {
+		for _, delta := range endpoint.Deltas {
+			if delta.Severity == "" {
+				continue
+			}
+			unit := "%"
+			if delta.Metric == "failure_rate" {
+				unit = " percentage points"
+			}
+			lines = append(lines, fmt.Sprintf("[%s] %s of %s regressed by %.2f%s (%.2f -> %.2f)",
+				delta.Severity, delta.Metric, endpoint.Endpoint, delta.Percent, unit, delta.Baseline, delta.Current))
+		}
+	}
+	return lines
+}
+
+// String summarizes the comparison for the message of the test.finished event
+func (c *Comparison) String() string {
+	if len(c.BaselineRuns) == 0 {
+		return "Baseline comparison: no previous successful runs available"
+	}
+
+	regressions := c.Regressions()
+	if len(regressions) == 0 {
+		return fmt.Sprintf("Baseline comparison against %d previous run(s): no regressions", len(c.BaselineRuns))
+	}
+	return fmt.Sprintf("Baseline comparison against %d previous run(s) found %d regression(s):\n%s",
+		len(c.BaselineRuns), len(regressions), strings.Join(regressions, "\n"))
+}
+
+func (c *Config) severity(percent float64) thresholds.Severity {
+	if c.FailPercent != nil && percent > *c.FailPercent {
+		return thresholds.SeverityFail
+	}
+	if c.WarningPercent != nil && percent > *c.WarningPercent {
+		return thresholds.SeverityWarning
+	}
+	return ""
+}
+
+func worstSeverity(a thresholds.Severity, b thresholds.Severity) thresholds.Severity {
+	if a == thresholds.SeverityFail || b == thresholds.SeverityFail {
+		return thresholds.SeverityFail
+	}
+	if a == thresholds.SeverityWarning || b == thresholds.SeverityWarning {
+		return thresholds.SeverityWarning
+	}
+	return ""
+}
+
+func average(values []*stats.RequestStats, value func(r *stats.RequestStats) float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += value(v)
+	}
+	return sum / float64(len(values))
+}
+
+// endpointName returns the name that identifies the endpoint in other runs
+func endpointName(requestStats *stats.RequestStats) string {
+	if requestStats.Type == "" {
+		return requestStats.Name
+	}
+	return requestStats.Type + " " + requestStats.Name
+}
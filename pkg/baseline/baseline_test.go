@@ -0,0 +1,78 @@
+package baseline
+
+import (
+	"testing"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/keptn-sandbox/locust-service/pkg/thresholds"
+	"github.com/stretchr/testify/assert"
+)
+
+func percent(value float64) *float64 {
+	return &value
+}
+
+func createStatistics(p95 float64, failureCount int64) *stats.Statistics {
+	return &stats.Statistics{
+		Endpoints: []stats.RequestStats{
+			{Type: "GET", Name: "/carts", RequestCount: 100, FailureCount: failureCount, P50: p95 / 2, P95: p95, P99: p95 * 2},
+		},
+		Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 100, FailureCount: failureCount, P50: p95 / 2, P95: p95, P99: p95 * 2},
+	}
+}
+
+func findDelta(comparison *Comparison, endpoint string, metric string) *Delta {
+	for _, endpointComparison := range comparison.Endpoints {
+		if endpointComparison.Endpoint != endpoint {
+			continue
+		}
+		for i := range endpointComparison.Deltas {
+			if endpointComparison.Deltas[i].Metric == metric {
+				return &endpointComparison.Deltas[i]
+			}
+		}
+	}
+	return nil
+}
+
+func TestCompare_NoRegression(t *testing.T) {
+	config := &Config{WarningPercent: percent(10), FailPercent: percent(25)}
+	baselines := []*stats.Statistics{createStatistics(100, 0), createStatistics(120, 0)}
+
+	comparison := Compare(config, createStatistics(115, 0), []string{"1", "2"}, baselines)
+	assert.Len(t, comparison.Endpoints, 2)
+	assert.Equal(t, thresholds.Severity(""), comparison.Severity)
+
+	delta := findDelta(comparison, "GET /carts", "p95")
+	assert.Equal(t, 110.0, delta.Baseline)
+	assert.InDelta(t, 4.545, delta.Percent, 0.001)
+	assert.Empty(t, comparison.Regressions())
+}
+
+func TestCompare_Regressions(t *testing.T) {
+	config := &Config{WarningPercent: percent(10), FailPercent: percent(25)}
+	baselines := []*stats.Statistics{createStatistics(100, 0)}
+
+	comparison := Compare(config, createStatistics(115, 0), []string{"1"}, baselines)
+	assert.Equal(t, thresholds.SeverityWarning, comparison.Severity)
+	assert.Equal(t, thresholds.SeverityWarning, findDelta(comparison, stats.AggregatedName, "p95").Severity)
+
+	comparison = Compare(config, createStatistics(100, 30), []string{"1"}, baselines)
+	assert.Equal(t, thresholds.SeverityFail, comparison.Severity)
+	delta := findDelta(comparison, "GET /carts", "failure_rate")
+	assert.Equal(t, 30.0, delta.Percent)
+	assert.Contains(t, comparison.String(), "failure_rate of GET /carts regressed by 30.00 percentage points")
+}
+
+func TestCompare_NoBaseline(t *testing.T) {
+	comparison := Compare(&Config{}, createStatistics(100, 0), nil, nil)
+	assert.Empty(t, comparison.Endpoints)
+	assert.Equal(t, "Baseline comparison: no previous successful runs available", comparison.String())
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Config{WarningPercent: percent(10), FailPercent: percent(20)}).Validate())
+	assert.Error(t, (&Config{WarningPercent: percent(30), FailPercent: percent(20)}).Validate())
+	assert.Error(t, (&Config{FailPercent: percent(-1)}).Validate())
+	assert.Equal(t, DefaultRuns, (&Config{}).RunCount())
+}
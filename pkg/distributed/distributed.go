@@ -0,0 +1,179 @@
+package distributed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/logstream"
+	"github.com/keptn-sandbox/locust-service/pkg/process"
+)
+
+// Options configure how Run supervises the master and the workers
+type Options struct {
+	// GracePeriod defines how long the processes may take to shut down after SIGTERM before they are killed
+	GracePeriod time.Duration
+	// Output receives the output of the master and the workers while they are running (optional). It is written
+	// line by line, the lines of the workers are prefixed with their number.
+	Output io.Writer
+	// OutputLimit is the number of bytes of the output of each process that is kept for the result and the errors
+	// of Run (default: logstream.DefaultLimit)
+	OutputLimit int
+}
+
+// Run starts a locust master expecting the given number of workers, as well as the workers themselves, and
+// supervises all of them. It returns once the master has exited, after every worker has been torn down.
+// If a worker fails while the master is still running, all processes are stopped and the error is returned.
+// If the context is done, the master and the workers are terminated gracefully, see process.Terminate.
+// Only the end of the output is returned, see Options.OutputLimit.
+func Run(ctx context.Context, command string, masterArgs []string, workerArgs []string, workers int, env []string, options Options) (string, error) {
+	if workers <= 0 {
+		return "", fmt.Errorf("at least one worker is required, got %d", workers)
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return "", fmt.Errorf("could not find a free port for the locust master: %w", err)
+	}
+
+	masterArgs = append(append([]string{}, masterArgs...),
+		"--master", fmt.Sprintf("--expect-workers=%d", workers), fmt.Sprintf("--master-bind-port=%d", port))
+	workerArgs = append(append([]string{}, workerArgs...),
+		"--worker", "--master-host=127.0.0.1", fmt.Sprintf("--master-port=%d", port))
+
+	output := &syncWriter{writer: options.Output}
+	masterOutput := logstream.NewRing(options.OutputLimit)
+	masterLines := output.lines("")
+	defer masterLines.Flush()
+	master := exec.Command(command, masterArgs...)
+	master.Env = append(master.Env, env...)
+	master.Stdout = io.MultiWriter(masterOutput, masterLines)
+	master.Stderr = master.Stdout
+	process.UseProcessGroup(master)
+
+	if err := master.Start(); err != nil {
+		return "", fmt.Errorf("Error executing command %s %s: %w", command, strings.Join(masterArgs, " "), err)
+	}
+
+	masterDone := make(chan error, 1)
+	go func() {
+		masterDone <- master.Wait()
+	}()
+
+	workerProcesses := make([]*exec.Cmd, 0, workers)
+	workerDone := make(chan workerResult, workers)
+	for i := 0; i < workers; i++ {
+		worker := exec.Command(command, workerArgs...)
+		worker.Env = append(worker.Env, env...)
+		workerOutput := logstream.NewRing(options.OutputLimit)
+		workerLines := output.lines(fmt.Sprintf("[worker %d] ", i))
+		defer workerLines.Flush()
+		worker.Stdout = io.MultiWriter(workerOutput, workerLines)
+		worker.Stderr = worker.Stdout
+		process.UseProcessGroup(worker)
+
+		if err := worker.Start(); err != nil {
+			killAll(master, workerProcesses)
+			<-masterDone
+			waitForWorkers(workerDone, len(workerProcesses))
+			return masterOutput.String(), fmt.Errorf("Error executing command %s %s: %w", command, strings.Join(workerArgs, " "), err)
+		}
+		workerProcesses = append(workerProcesses, worker)
+
+		go func(index int) {
+			workerDone <- workerResult{index: index, err: worker.Wait(), output: workerOutput}
+		}(i)
+	}
+
+	log.Printf("Started locust master on port %d with %d workers", port, workers)
+
+	finishedWorkers := 0
+	for {
+		select {
+		case err := <-masterDone:
+			killAll(nil, workerProcesses)
+			waitForWorkers(workerDone, workers-finishedWorkers)
+			if err != nil {
+				return "", fmt.Errorf("Error executing command %s %s: %w\n%s", command, strings.Join(masterArgs, " "), err, masterOutput.String())
+			}
+			return masterOutput.String(), nil
+
+		case result := <-workerDone:
+			finishedWorkers++
+			if result.err == nil {
+				continue
+			}
+			killAll(master, workerProcesses)
+			<-masterDone
+			waitForWorkers(workerDone, workers-finishedWorkers)
+			return "", fmt.Errorf("Error executing locust worker %d: %w\n%s\n%s", result.index, result.err, result.output.String(), masterOutput.String())
+
+		case <-ctx.Done():
+			// the master writes the statistics collected so far when it is terminated
+			for _, worker := range workerProcesses {
+				process.Signal(worker, syscall.SIGTERM)
+			}
+			process.Terminate(master, options.GracePeriod, masterDone)
+			killAll(nil, workerProcesses)
+			waitForWorkers(workerDone, workers-finishedWorkers)
+			return "", fmt.Errorf("Error executing command %s %s: %w\n%s", command, strings.Join(masterArgs, " "), ctx.Err(), masterOutput.String())
+		}
+	}
+}
+
+type workerResult struct {
+	index  int
+	err    error
+	output *logstream.Ring
+}
+
+// syncWriter serializes the lines of the processes written to the output of Run
+type syncWriter struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// lines returns a writer passing the complete lines of a process to the output, prefixed with the given prefix
+func (w *syncWriter) lines(prefix string) *logstream.LineWriter {
+	return logstream.NewLineWriter(func(line string) {
+		if w.writer == nil {
+			return
+		}
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+		io.WriteString(w.writer, prefix+line+"\n")
+	})
+}
+
+// killAll kills the master (if given) and all workers, processes that have already exited are ignored
+func killAll(master *exec.Cmd, workers []*exec.Cmd) {
+	if master != nil {
+		process.Kill(master)
+	}
+	for _, worker := range workers {
+		process.Kill(worker)
+	}
+}
+
+func waitForWorkers(workerDone chan workerResult, count int) {
+	for i := 0; i < count; i++ {
+		<-workerDone
+	}
+}
+
+// freePort asks the kernel for a free TCP port the master can bind to
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,93 @@
+package distributed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createFakeLocust writes a shell script that behaves like the master or the worker depending on its arguments
+func createFakeLocust(t *testing.T, master string, worker string) string {
+	dir, err := ioutil.TempDir("", "distributed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	script := `#!/bin/sh
+for arg in "$@"; do
+  case "$arg" in
+    --master) ` + master + ` ;;
+    --worker) ` + worker + ` ;;
+  esac
+done
+exit 2
+`
+	filename := filepath.Join(dir, "locust")
+	if err := ioutil.WriteFile(filename, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestRun(t *testing.T) {
+	command := createFakeLocust(t, `echo "master $MY_VAR"; exit 0`, `sleep 10; exit 0`)
+
+	start := time.Now()
+	output, err := Run(context.Background(), command, []string{"--headless"}, []string{}, 2, []string{"MY_VAR=finished"}, Options{GracePeriod: time.Second})
+	assert.NoError(t, err)
+	assert.Contains(t, output, "master finished")
+	// the workers are torn down once the master is done
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestRun_Output(t *testing.T) {
+	command := createFakeLocust(t, `sleep 1; echo "master done"; exit 0`, `echo "worker ready"; sleep 10; exit 0`)
+
+	var output bytes.Buffer
+	_, err := Run(context.Background(), command, []string{}, []string{}, 1, []string{}, Options{GracePeriod: time.Second, Output: &output})
+	assert.NoError(t, err)
+	assert.Equal(t, "[worker 0] worker ready\nmaster done\n", output.String())
+}
+
+func TestRun_MasterFails(t *testing.T) {
+	command := createFakeLocust(t, `echo "master failed"; exit 1`, `sleep 10; exit 0`)
+
+	_, err := Run(context.Background(), command, []string{}, []string{}, 1, []string{}, Options{GracePeriod: time.Second})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "master failed")
+}
+
+func TestRun_WorkerFails(t *testing.T) {
+	command := createFakeLocust(t, `sleep 10; exit 0`, `echo "worker failed"; exit 1`)
+
+	start := time.Now()
+	_, err := Run(context.Background(), command, []string{}, []string{}, 2, []string{}, Options{GracePeriod: time.Second})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "worker failed")
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestRun_NoWorkers(t *testing.T) {
+	_, err := Run(context.Background(), "locust", []string{}, []string{}, 0, []string{}, Options{GracePeriod: time.Second})
+	assert.Error(t, err)
+}
+
+func TestRun_Canceled(t *testing.T) {
+	command := createFakeLocust(t, `sleep 10; exit 0`, `sleep 10; exit 0`)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := Run(ctx, command, []string{}, []string{}, 2, []string{}, Options{GracePeriod: time.Second})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/client-go/kubernetes/typed/core/v1"
 )
@@ -16,6 +17,8 @@ const secretPrefix = "locust"
 type EnvironmentProvider struct {
 	KubeAPI                v1.CoreV1Interface
 	KeptnNamespaceProvider StringSupplier
+	// OnLookupFailure is called if a secret exists but cannot be read (optional)
+	OnLookupFailure func(secretName string, err error)
 }
 
 func NewEnvironmentProvider(kubeAPI v1.CoreV1Interface) *EnvironmentProvider {
@@ -28,12 +31,15 @@ func NewEnvironmentProvider(kubeAPI v1.CoreV1Interface) *EnvironmentProvider {
 // PrepareEnvironment creates a list of environment variables by extracting them from a secret based on project, stage and service
 func (e EnvironmentProvider) PrepareEnvironment(project string, stage string, service string) []string {
 	environment := []string{}
-	secretName := fmt.Sprintf("%s-%s-%s-%s", secretPrefix, project, stage, service)
+	secretName := e.SecretName(project, stage, service)
 	log.Printf("Prepare data of secret %s as environment", secretName)
 
 	secret, err := e.KubeAPI.Secrets(e.KeptnNamespaceProvider()).Get(context.TODO(), secretName, metav1.GetOptions{})
 	if err != nil {
 		log.Printf("Unable to get secret %s: %s", secretName, err)
+		if !k8serrors.IsNotFound(err) && e.OnLookupFailure != nil {
+			e.OnLookupFailure(secretName, err)
+		}
 		return environment
 	}
 
@@ -43,6 +49,11 @@ func (e EnvironmentProvider) PrepareEnvironment(project string, stage string, se
 	return environment
 }
 
+// SecretName returns the name of the secret holding the environment variables for project, stage and service
+func (e EnvironmentProvider) SecretName(project string, stage string, service string) string {
+	return fmt.Sprintf("%s-%s-%s-%s", secretPrefix, project, stage, service)
+}
+
 type StringSupplier func() string
 
 func envBasedStringSupplier(envVarName, defaultVal string) StringSupplier {
@@ -2,13 +2,16 @@ package environment
 
 import (
 	"context"
+	"errors"
 	"os/exec"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func createK8sSecretObj(name string, namespace string, data map[string][]byte) *corev1.Secret {
@@ -81,3 +84,27 @@ func TestPrepareEnvironment_ComplexValue(t *testing.T) {
 	assertEnvironmentVariable(t, environment, key1, value1)
 	assertEnvironmentVariable(t, environment, key2, value2)
 }
+
+func TestPrepareEnvironment_LookupFailure(t *testing.T) {
+	kubernetes := k8sfake.NewSimpleClientset()
+	kubernetes.PrependReactor("get", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, errors.New("forbidden")
+	})
+	environmentProvider := NewEnvironmentProvider(kubernetes.CoreV1())
+
+	failures := []string{}
+	environmentProvider.OnLookupFailure = func(secretName string, err error) {
+		failures = append(failures, secretName)
+	}
+
+	environment := environmentProvider.PrepareEnvironment("project", "stage", "service")
+	assert.Empty(t, environment)
+	assert.Equal(t, []string{"locust-project-stage-service"}, failures)
+
+	// a missing secret is not a lookup failure
+	environmentProvider = NewEnvironmentProvider(k8sfake.NewSimpleClientset().CoreV1())
+	environmentProvider.OnLookupFailure = func(secretName string, err error) {
+		t.Errorf("unexpected lookup failure for %s", secretName)
+	}
+	environmentProvider.PrepareEnvironment("project", "stage", "service")
+}
@@ -0,0 +1,115 @@
+package history
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/httpjson"
+)
+
+// RunsPath is the path under which the run history is served
+const RunsPath = "/runs"
+
+// Handler serves the run history via HTTP:
+//
+//	GET /runs?project=&stage=&service=&teststrategy=&workload=&schedule=&keptnContext=&result=&since=<RFC3339>&limit=<n>
+//	GET /runs/<id>
+//	DELETE /runs/<id> (cancels a queued or running run)
+type Handler struct {
+	Store *Store
+	// Cancel cancels the run with the given id and returns false if it is not queued or running (optional)
+	Cancel func(id string) bool
+}
+
+// NewHandler creates a new Handler for the given store
+func NewHandler(store *Store) *Handler {
+	return &Handler{
+		Store: store,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, RunsPath), "/")
+
+	if r.Method == http.MethodDelete && id != "" && h.Cancel != nil {
+		h.cancelRun(w, id)
+		return
+	}
+	if r.Method != http.MethodGet {
+		httpjson.Error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if id == "" {
+		h.listRuns(w, r)
+		return
+	}
+
+	run, err := h.Store.Get(id)
+	if err == ErrRunNotFound {
+		httpjson.Error(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		httpjson.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httpjson.Write(w, http.StatusOK, run)
+}
+
+func (h *Handler) listRuns(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := Filter{
+		KeptnContext: query.Get("keptnContext"),
+		Project:      query.Get("project"),
+		Stage:        query.Get("stage"),
+		Service:      query.Get("service"),
+		TestStrategy: query.Get("teststrategy"),
+		WorkloadName: query.Get("workload"),
+		Schedule:     query.Get("schedule"),
+		Result:       query.Get("result"),
+	}
+
+	if since := query.Get("since"); since != "" {
+		parsed, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			httpjson.Error(w, http.StatusBadRequest, "invalid since parameter: "+err.Error())
+			return
+		}
+		filter.Since = parsed
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 0 {
+			httpjson.Error(w, http.StatusBadRequest, "invalid limit parameter")
+			return
+		}
+		filter.Limit = parsed
+	}
+
+	runs, err := h.Store.List(filter)
+	if err != nil {
+		httpjson.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httpjson.Write(w, http.StatusOK, runs)
+}
+
+func (h *Handler) cancelRun(w http.ResponseWriter, id string) {
+	if h.Cancel(id) {
+		httpjson.Write(w, http.StatusAccepted, map[string]string{"id": id, "message": "run is being aborted"})
+		return
+	}
+
+	_, err := h.Store.Get(id)
+	if err == ErrRunNotFound {
+		httpjson.Error(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		httpjson.Error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	httpjson.Error(w, http.StatusConflict, "run is not running")
+}
@@ -0,0 +1,73 @@
+package history
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	store := createStore(t)
+	now := time.Now().UTC()
+	assert.NoError(t, store.Save(createRun("1", "dev", now.Add(-2*time.Hour), "pass")))
+	assert.NoError(t, store.Save(createRun("2", "staging", now.Add(-1*time.Hour), "fail")))
+
+	handler := NewHandler(store)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/runs?stage=staging", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	runs := []*Run{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &runs))
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "2", runs[0].ID)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/runs/1", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	run := &Run{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), run))
+	assert.Equal(t, "context-1", run.KeptnContext)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/runs/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/runs?limit=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/runs", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
+
+func TestHandler_Cancel(t *testing.T) {
+	store := createStore(t)
+	assert.NoError(t, store.Save(createRun("1", "dev", time.Now().UTC(), "pass")))
+
+	handler := NewHandler(store)
+	handler.Cancel = func(id string) bool {
+		return id == "running"
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/runs/running", nil))
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/runs/1", nil))
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/runs/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/runs", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
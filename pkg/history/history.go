@@ -0,0 +1,364 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	runsBucket = []byte("runs")
+	// the index buckets hold keys ending with the start time and the ID of a run, so the runs of a key are sorted
+	// by their start and List does not have to read every run
+	contextIndexBucket  = []byte("runs-by-context")
+	workloadIndexBucket = []byte("runs-by-workload")
+	startIndexBucket    = []byte("runs-by-start")
+)
+
+// separator separates the parts of an index key, it is not expected in any field of a run
+const separator = "\x00"
+
+// startFormat formats the start time of a run in the index keys, so their order is the order of the start times
+const startFormat = "20060102T150405.000000000"
+
+// ErrRunNotFound is returned if no run with the requested ID exists
+var ErrRunNotFound = errors.New("run not found")
+
+// Run is the record of a single locust run
+type Run struct {
+	ID           string            `json:"id"`
+	KeptnContext string            `json:"keptnContext"`
+	TriggeredID  string            `json:"triggeredId"`
+	Project      string            `json:"project"`
+	Stage        string            `json:"stage"`
+	Service      string            `json:"service"`
+	TestStrategy string            `json:"testStrategy"`
+	WorkloadName string            `json:"workloadName,omitempty"`
+	Schedule     string            `json:"schedule,omitempty"`
+	Workload     interface{}       `json:"workload,omitempty"`
+	Arguments    []string          `json:"arguments,omitempty"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	ExitCode     int               `json:"exitCode"`
+	Status       string            `json:"status"`
+	Result       string            `json:"result"`
+	Message      string            `json:"message,omitempty"`
+	Statistics   *stats.Statistics `json:"statistics,omitempty"`
+	// Output is the end of the output of locust, also of successful runs
+	Output string `json:"output,omitempty"`
+}
+
+// Filter restricts the runs returned by Store.List. Empty fields match every run.
+type Filter struct {
+	KeptnContext string
+	Project      string
+	Stage        string
+	Service      string
+	TestStrategy string
+	WorkloadName string
+	Schedule     string
+	Result       string
+	Since        time.Time
+	Limit        int
+	// ExcludeScheduled skips the runs started by a schedule, it cannot be combined with Schedule
+	ExcludeScheduled bool
+}
+
+func (f Filter) matches(run *Run) bool {
+	return matchesField(f.KeptnContext, run.KeptnContext) &&
+		matchesField(f.Project, run.Project) &&
+		matchesField(f.Stage, run.Stage) &&
+		matchesField(f.Service, run.Service) &&
+		matchesField(f.TestStrategy, run.TestStrategy) &&
+		matchesField(f.WorkloadName, run.WorkloadName) &&
+		matchesField(f.Schedule, run.Schedule) &&
+		matchesField(f.Result, run.Result) &&
+		!(f.ExcludeScheduled && run.Schedule != "") &&
+		!run.Start.Before(f.Since)
+}
+
+func matchesField(filter string, value string) bool {
+	return filter == "" || filter == value
+}
+
+// Store persists the history of locust runs in a single bolt database file
+type Store struct {
+	db *bolt.DB
+	// MaxAge is the time after which runs are removed by Clean, counted from their start (0 retains them)
+	MaxAge time.Duration
+}
+
+// Open opens (or creates) the history database at the given path
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		runs, err := tx.CreateBucketIfNotExists(runsBucket)
+		if err != nil {
+			return err
+		}
+		// databases written before the indexes have been introduced are indexed once
+		if tx.Bucket(startIndexBucket) != nil {
+			return nil
+		}
+		for _, name := range [][]byte{contextIndexBucket, workloadIndexBucket, startIndexBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return runs.ForEach(func(_, content []byte) error {
+			run := &Run{}
+			if err := json.Unmarshal(content, run); err != nil {
+				return err
+			}
+			return putIndex(tx, run)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save creates or replaces the record of a run
+func (s *Store) Save(run *Run) error {
+	if run.ID == "" {
+		return errors.New("run must have an ID")
+	}
+
+	content, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := deleteRun(tx, run.ID); err != nil {
+			return err
+		}
+		if err := tx.Bucket(runsBucket).Put([]byte(run.ID), content); err != nil {
+			return err
+		}
+		return putIndex(tx, run)
+	})
+}
+
+// Get returns the run with the given ID
+func (s *Store) Get(id string) (*Run, error) {
+	run := &Run{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		content := tx.Bucket(runsBucket).Get([]byte(id))
+		if content == nil {
+			return ErrRunNotFound
+		}
+		return json.Unmarshal(content, run)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// Delete removes the record of a run, removing an unknown run is not an error
+func (s *Store) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteRun(tx, id)
+	})
+}
+
+// List returns all runs matching the filter, the most recent run first. Filters on the keptnContext or on project,
+// stage, service and test strategy only read the runs of the corresponding index.
+func (s *Store) List(filter Filter) ([]*Run, error) {
+	runs := []*Run{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket, prefix := filter.index()
+		if bucket == nil {
+			return tx.Bucket(runsBucket).ForEach(func(_, content []byte) error {
+				run := &Run{}
+				if err := json.Unmarshal(content, run); err != nil {
+					return err
+				}
+				if filter.matches(run) {
+					runs = append(runs, run)
+				}
+				return nil
+			})
+		}
+
+		// the index is read from the most recent run backwards, so the reading stops once the limit is reached
+		cursor := tx.Bucket(bucket).Cursor()
+		key, _ := cursor.Seek(prefixEnd(prefix))
+		if key == nil {
+			key, _ = cursor.Last()
+		} else {
+			key, _ = cursor.Prev()
+		}
+		for ; key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Prev() {
+			if filter.Limit > 0 && len(runs) >= filter.Limit {
+				break
+			}
+			content := tx.Bucket(runsBucket).Get(indexedID(key))
+			if content == nil {
+				continue
+			}
+			run := &Run{}
+			if err := json.Unmarshal(content, run); err != nil {
+				return err
+			}
+			if filter.matches(run) {
+				runs = append(runs, run)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// stable, so runs with the same start keep the order of the index
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].Start.After(runs[j].Start)
+	})
+
+	if filter.Limit > 0 && len(runs) > filter.Limit {
+		runs = runs[:filter.Limit]
+	}
+	return runs, nil
+}
+
+// Clean removes the runs that have been started longer than MaxAge ago and returns their IDs
+func (s *Store) Clean() ([]string, error) {
+	removed := []string{}
+	if s.MaxAge <= 0 {
+		return removed, nil
+	}
+
+	end := []byte(time.Now().Add(-s.MaxAge).UTC().Format(startFormat))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		ids := []string{}
+		cursor := tx.Bucket(startIndexBucket).Cursor()
+		for key, _ := cursor.First(); key != nil && bytes.Compare(key, end) < 0; key, _ = cursor.Next() {
+			ids = append(ids, string(indexedID(key)))
+		}
+		for _, id := range ids {
+			if err := deleteRun(tx, id); err != nil {
+				return err
+			}
+			removed = append(removed, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// RunJanitor cleans the history right away and then in the given interval, until the context is done
+func (s *Store) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := s.Clean()
+		if err != nil {
+			log.Printf("Could not clean run history: %s", err.Error())
+		} else if len(removed) > 0 {
+			log.Printf("Removed %d runs from the run history", len(removed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// index returns the index bucket and the key prefix of the runs the filter can match, or nil if every run has to
+// be read
+func (f Filter) index() ([]byte, []byte) {
+	if f.KeptnContext != "" {
+		return contextIndexBucket, indexKey(f.KeptnContext, "")
+	}
+	if f.Project == "" || f.Stage == "" || f.Service == "" || f.TestStrategy == "" {
+		return nil, nil
+	}
+	if f.WorkloadName == "" {
+		// runs of any workload match
+		return workloadIndexBucket, indexKey(f.Project, f.Stage, f.Service, f.TestStrategy, "")
+	}
+	return workloadIndexBucket, indexKey(f.Project, f.Stage, f.Service, f.TestStrategy, f.WorkloadName, "")
+}
+
+// indexKeys returns the keys of the run in each index bucket
+func indexKeys(run *Run) map[string][]byte {
+	start := run.Start.UTC().Format(startFormat)
+	return map[string][]byte{
+		string(contextIndexBucket):  indexKey(run.KeptnContext, start, run.ID),
+		string(workloadIndexBucket): indexKey(run.Project, run.Stage, run.Service, run.TestStrategy, run.WorkloadName, start, run.ID),
+		string(startIndexBucket):    indexKey(start, run.ID),
+	}
+}
+
+func indexKey(parts ...string) []byte {
+	return []byte(strings.Join(parts, separator))
+}
+
+// indexedID returns the ID of the run an index key belongs to
+func indexedID(key []byte) []byte {
+	return key[bytes.LastIndex(key, []byte(separator))+1:]
+}
+
+// prefixEnd returns the first key following all keys with the given prefix, which ends with the separator
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	end[len(end)-1]++
+	return end
+}
+
+func putIndex(tx *bolt.Tx, run *Run) error {
+	for bucket, key := range indexKeys(run) {
+		if err := tx.Bucket([]byte(bucket)).Put(key, []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteRun removes the run and its index keys, removing an unknown run is not an error
+func deleteRun(tx *bolt.Tx, id string) error {
+	content := tx.Bucket(runsBucket).Get([]byte(id))
+	if content == nil {
+		return nil
+	}
+	run := &Run{}
+	if err := json.Unmarshal(content, run); err != nil {
+		return err
+	}
+	for bucket, key := range indexKeys(run) {
+		if err := tx.Bucket([]byte(bucket)).Delete(key); err != nil {
+			return err
+		}
+	}
+	return tx.Bucket(runsBucket).Delete([]byte(id))
+}
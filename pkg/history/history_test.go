@@ -0,0 +1,236 @@
+package history
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func createStore(t *testing.T) *Store {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	store, err := Open(filepath.Join(dir, "history.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func createRun(id string, stage string, start time.Time, result string) *Run {
+	return &Run{
+		ID:           id,
+		KeptnContext: "context-" + id,
+		Project:      "sockshop",
+		Stage:        stage,
+		Service:      "carts",
+		TestStrategy: "performance",
+		Start:        start,
+		End:          start.Add(time.Minute),
+		Status:       "succeeded",
+		Result:       result,
+		Statistics: &stats.Statistics{
+			Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 100},
+		},
+	}
+}
+
+func TestSaveAndGet(t *testing.T) {
+	store := createStore(t)
+	run := createRun("1", "dev", time.Now().UTC().Truncate(time.Second), "pass")
+
+	assert.NoError(t, store.Save(run))
+
+	loaded, err := store.Get("1")
+	assert.NoError(t, err)
+	assert.Equal(t, run, loaded)
+
+	_, err = store.Get("unknown")
+	assert.Equal(t, ErrRunNotFound, err)
+}
+
+func TestDelete(t *testing.T) {
+	store := createStore(t)
+	assert.NoError(t, store.Save(createRun("1", "dev", time.Now(), "pass")))
+
+	assert.NoError(t, store.Delete("1"))
+	_, err := store.Get("1")
+	assert.Equal(t, ErrRunNotFound, err)
+	assert.NoError(t, store.Delete("unknown"))
+}
+
+func TestSave_MissingID(t *testing.T) {
+	store := createStore(t)
+
+	assert.Error(t, store.Save(&Run{}))
+}
+
+func TestList(t *testing.T) {
+	store := createStore(t)
+	now := time.Now().UTC()
+
+	assert.NoError(t, store.Save(createRun("1", "dev", now.Add(-3*time.Hour), "pass")))
+	assert.NoError(t, store.Save(createRun("2", "dev", now.Add(-2*time.Hour), "fail")))
+	assert.NoError(t, store.Save(createRun("3", "staging", now.Add(-1*time.Hour), "pass")))
+
+	runs, err := store.List(Filter{})
+	assert.NoError(t, err)
+	assert.Len(t, runs, 3)
+	assert.Equal(t, "3", runs[0].ID)
+	assert.Equal(t, "1", runs[2].ID)
+
+	runs, err = store.List(Filter{Stage: "dev"})
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	runs, err = store.List(Filter{Stage: "dev", Result: "pass"})
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "1", runs[0].ID)
+
+	runs, err = store.List(Filter{Since: now.Add(-90 * time.Minute)})
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+
+	runs, err = store.List(Filter{Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, runs, 2)
+
+	checkout := createRun("4", "dev", now, "pass")
+	checkout.WorkloadName = "checkout"
+	assert.NoError(t, store.Save(checkout))
+	runs, err = store.List(Filter{WorkloadName: "checkout"})
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "4", runs[0].ID)
+
+	scheduled := createRun("5", "production", now, "pass")
+	scheduled.Schedule = "sockshop/production/carts/warm-caches"
+	assert.NoError(t, store.Save(scheduled))
+	runs, err = store.List(Filter{Schedule: "sockshop/production/carts/warm-caches"})
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, "5", runs[0].ID)
+
+	runs, err = store.List(Filter{Stage: "production", ExcludeScheduled: true})
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestList_Indexed(t *testing.T) {
+	store := createStore(t)
+	now := time.Now().UTC()
+
+	for i, workload := range []string{"browse", "checkout", "browse"} {
+		run := createRun(strconv.Itoa(i+1), "dev", now.Add(time.Duration(i)*time.Minute), "pass")
+		run.KeptnContext = "context-a"
+		run.WorkloadName = workload
+		assert.NoError(t, store.Save(run))
+	}
+	assert.NoError(t, store.Save(createRun("4", "dev", now, "pass")))
+
+	runs, err := store.List(Filter{KeptnContext: "context-a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3", "2", "1"}, runIDs(runs))
+
+	runs, err = store.List(Filter{Project: "sockshop", Stage: "dev", Service: "carts", TestStrategy: "performance", WorkloadName: "browse"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3", "1"}, runIDs(runs))
+
+	runs, err = store.List(Filter{Project: "sockshop", Stage: "dev", Service: "carts", TestStrategy: "performance", Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3", "2"}, runIDs(runs))
+
+	// replacing a run updates its index keys
+	moved := createRun("3", "staging", now, "pass")
+	moved.KeptnContext = "context-a"
+	moved.WorkloadName = "browse"
+	assert.NoError(t, store.Save(moved))
+	runs, err = store.List(Filter{Project: "sockshop", Stage: "dev", Service: "carts", TestStrategy: "performance", WorkloadName: "browse"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, runIDs(runs))
+	runs, err = store.List(Filter{KeptnContext: "context-a"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2", "3", "1"}, runIDs(runs))
+
+	assert.NoError(t, store.Delete("2"))
+	runs, err = store.List(Filter{Project: "sockshop", Stage: "dev", Service: "carts", TestStrategy: "performance", WorkloadName: "checkout"})
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func TestOpen_IndexesExistingRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history.db")
+
+	// a database written before the indexes have been introduced
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := json.Marshal(createRun("1", "dev", time.Now(), "pass"))
+	assert.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucket(runsBucket)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte("1"), content)
+	}))
+	assert.NoError(t, db.Close())
+
+	store, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	runs, err := store.List(Filter{KeptnContext: "context-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, runIDs(runs))
+}
+
+func TestClean(t *testing.T) {
+	store := createStore(t)
+	now := time.Now()
+	assert.NoError(t, store.Save(createRun("1", "dev", now.Add(-3*time.Hour), "pass")))
+	assert.NoError(t, store.Save(createRun("2", "dev", now, "pass")))
+
+	// without MaxAge every run is retained
+	removed, err := store.Clean()
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	store.MaxAge = time.Hour
+	removed, err = store.Clean()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1"}, removed)
+	runs, err := store.List(Filter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"2"}, runIDs(runs))
+	runs, err = store.List(Filter{KeptnContext: "context-1"})
+	assert.NoError(t, err)
+	assert.Empty(t, runs)
+}
+
+func runIDs(runs []*Run) []string {
+	ids := []string{}
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+	return ids
+}
@@ -0,0 +1,21 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Write writes the body as JSON response with the given status
+func Write(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Could not write response: %s", err.Error())
+	}
+}
+
+// Error writes a JSON response with the given status and {"message": message} as body
+func Error(w http.ResponseWriter, status int, message string) {
+	Write(w, status, map[string]string{"message": message})
+}
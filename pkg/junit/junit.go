@@ -0,0 +1,164 @@
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/keptn-sandbox/locust-service/pkg/thresholds"
+)
+
+// Filename is the name of the JUnit XML report in the run workspace
+const Filename = "junit.xml"
+
+// TestSuites is the root element of a JUnit XML report
+type TestSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Time     float64     `xml:"time,attr"`
+	Suites   []TestSuite `xml:"testsuite"`
+}
+
+// TestSuite groups the test cases of a single locust run
+type TestSuite struct {
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Errors    int        `xml:"errors,attr"`
+	Time      float64    `xml:"time,attr"`
+	Timestamp string     `xml:"timestamp,attr,omitempty"`
+	TestCases []TestCase `xml:"testcase"`
+}
+
+// TestCase represents a single endpoint of a locust run
+type TestCase struct {
+	Name      string    `xml:"name,attr"`
+	ClassName string    `xml:"classname,attr"`
+	Time      float64   `xml:"time,attr"`
+	Failures  []Failure `xml:"failure,omitempty"`
+	Errors    []Failure `xml:"error,omitempty"`
+	SystemOut string    `xml:"system-out,omitempty"`
+}
+
+// RunTestCaseName is the name of the test case reporting that the locust run itself has errored or timed out
+const RunTestCaseName = "locust run"
+
+// Failure is a violated threshold or an error reported by locust
+type Failure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Generate creates a JUnit XML report with one test case per endpoint. Violated thresholds and the errors
+// locust recorded for an endpoint are reported as failures of the test case. If the run has not completed, e.g.
+// because locust crashed or timed out, runError is reported as error of an additional test case.
+func Generate(name string, start time.Time, duration time.Duration, statistics *stats.Statistics, violations []thresholds.Violation, runError string) ([]byte, error) {
+	suite := TestSuite{
+		Name:      name,
+		Time:      duration.Seconds(),
+		Timestamp: start.UTC().Format("2006-01-02T15:04:05"),
+	}
+
+	if statistics != nil {
+		for _, requestStats := range statistics.Endpoints {
+			suite.TestCases = append(suite.TestCases, newTestCase(name, requestStats, statistics.Failures))
+		}
+		suite.TestCases = append(suite.TestCases, newTestCase(name, statistics.Aggregated, nil))
+	}
+
+	for _, violation := range violations {
+		index := findTestCase(suite.TestCases, violation.Endpoint)
+		if index < 0 {
+			// thresholds can reference endpoints that did not receive any requests
+			suite.TestCases = append(suite.TestCases, TestCase{Name: violation.Endpoint, ClassName: name})
+			index = len(suite.TestCases) - 1
+		}
+		suite.TestCases[index].Failures = append(suite.TestCases[index].Failures, Failure{
+			Message: violation.String(),
+			Type:    "threshold",
+		})
+	}
+
+	if runError != "" {
+		suite.TestCases = append(suite.TestCases, TestCase{
+			Name:      RunTestCaseName,
+			ClassName: name,
+			Time:      duration.Seconds(),
+			Errors:    []Failure{{Message: runError, Type: "run"}},
+		})
+	}
+
+	for _, testCase := range suite.TestCases {
+		suite.Tests++
+		if len(testCase.Errors) > 0 {
+			suite.Errors++
+		} else if len(testCase.Failures) > 0 {
+			suite.Failures++
+		}
+	}
+
+	report := TestSuites{
+		Name:     name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Errors:   suite.Errors,
+		Time:     suite.Time,
+		Suites:   []TestSuite{suite},
+	}
+
+	output, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), output...), nil
+}
+
+func newTestCase(className string, requestStats stats.RequestStats, failures []stats.Failure) TestCase {
+	testCase := TestCase{
+		Name:      testCaseName(requestStats.Type, requestStats.Name),
+		ClassName: className,
+		Time:      requestStats.AverageResponseTime / 1000,
+		SystemOut: fmt.Sprintf("requests=%d failures=%d rps=%.2f p50=%.0fms p95=%.0fms p99=%.0fms",
+			requestStats.RequestCount, requestStats.FailureCount, requestStats.RequestsPerSecond,
+			requestStats.P50, requestStats.P95, requestStats.P99),
+	}
+
+	for _, failure := range failures {
+		if failure.Name == requestStats.Name && (failure.Method == "" || failure.Method == requestStats.Type) {
+			testCase.Failures = append(testCase.Failures, Failure{
+				Message: fmt.Sprintf("%d occurrence(s): %s", failure.Occurrences, failure.Error),
+				Type:    "locust",
+				Text:    failure.Error,
+			})
+		}
+	}
+	return testCase
+}
+
+// findTestCase returns the index of the test case for the given endpoint, which can be prefixed with the request type
+func findTestCase(testCases []TestCase, endpoint string) int {
+	for i, testCase := range testCases {
+		if testCase.Name == endpoint {
+			return i
+		}
+	}
+	for i, testCase := range testCases {
+		if strings.HasSuffix(testCase.Name, " "+endpoint) {
+			return i
+		}
+	}
+	return -1
+}
+
+func testCaseName(requestType string, name string) string {
+	if requestType == "" {
+		return name
+	}
+	return requestType + " " + name
+}
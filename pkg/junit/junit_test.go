@@ -0,0 +1,77 @@
+package junit
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/keptn-sandbox/locust-service/pkg/thresholds"
+	"github.com/stretchr/testify/assert"
+)
+
+func createStatistics() *stats.Statistics {
+	return &stats.Statistics{
+		Endpoints: []stats.RequestStats{
+			{Type: "GET", Name: "/", RequestCount: 100, P95: 25},
+			{Type: "GET", Name: "/carts", RequestCount: 50, FailureCount: 5, P95: 80},
+		},
+		Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 150, FailureCount: 5, P95: 60},
+		Failures: []stats.Failure{
+			{Method: "GET", Name: "/carts", Error: "HTTPError('500 Server Error')", Occurrences: 5},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	violations := []thresholds.Violation{
+		{Endpoint: "/carts", Metric: "p95", Limit: 50, Actual: 80, Severity: thresholds.SeverityFail},
+		{Endpoint: stats.AggregatedName, Metric: "p95", Limit: 50, Actual: 60, Severity: thresholds.SeverityWarning},
+		{Endpoint: "/orders", Metric: "requests", Severity: thresholds.SeverityFail},
+	}
+
+	output, err := Generate("performance", time.Now(), 2*time.Minute, createStatistics(), violations, "")
+	assert.NoError(t, err)
+
+	report := TestSuites{}
+	assert.NoError(t, xml.Unmarshal(output, &report))
+	assert.Equal(t, 4, report.Tests)
+	assert.Equal(t, 3, report.Failures)
+	assert.Equal(t, 120.0, report.Time)
+
+	testCases := report.Suites[0].TestCases
+	assert.Equal(t, "GET /", testCases[0].Name)
+	assert.Empty(t, testCases[0].Failures)
+	assert.Equal(t, "GET /carts", testCases[1].Name)
+	assert.Len(t, testCases[1].Failures, 2)
+	assert.Equal(t, "locust", testCases[1].Failures[0].Type)
+	assert.Equal(t, "threshold", testCases[1].Failures[1].Type)
+	assert.Equal(t, stats.AggregatedName, testCases[2].Name)
+	assert.Len(t, testCases[2].Failures, 1)
+	assert.Equal(t, "/orders", testCases[3].Name)
+}
+
+func TestGenerate_NoStatistics(t *testing.T) {
+	output, err := Generate("performance", time.Now(), time.Minute, nil, nil, "")
+	assert.NoError(t, err)
+
+	report := TestSuites{}
+	assert.NoError(t, xml.Unmarshal(output, &report))
+	assert.Equal(t, 0, report.Tests)
+}
+
+func TestGenerate_RunError(t *testing.T) {
+	output, err := Generate("performance", time.Now(), time.Minute, createStatistics(), nil, "Locust test timed out after 1m")
+	assert.NoError(t, err)
+
+	report := TestSuites{}
+	assert.NoError(t, xml.Unmarshal(output, &report))
+	assert.Equal(t, 4, report.Tests)
+	assert.Equal(t, 1, report.Failures)
+	assert.Equal(t, 1, report.Errors)
+
+	testCases := report.Suites[0].TestCases
+	assert.Equal(t, "locust", testCases[1].Failures[0].Type)
+	assert.Equal(t, RunTestCaseName, testCases[3].Name)
+	assert.Equal(t, "Locust test timed out after 1m", testCases[3].Errors[0].Message)
+}
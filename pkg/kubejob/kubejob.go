@@ -0,0 +1,458 @@
+package kubejob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultImage is the image the locust job runs with if no other image is configured
+	DefaultImage = "locustio/locust"
+	// ContainerName is the name of the container running locust
+	ContainerName = "locust"
+
+	scriptsVolume = "scripts"
+	scriptsPath   = "/locust-service/scripts"
+	workVolume    = "workdir"
+
+	// logTimeout is how long the logs are read after the job has finished
+	logTimeout = 30 * time.Second
+	// stopMargin is the time the pod of a stopped job gets to upload its results in addition to its grace period
+	stopMargin = 30 * time.Second
+	// MaxScriptsSize is the maximum total size of the scripts of a run, as Kubernetes limits a ConfigMap to 1 MiB
+	MaxScriptsSize = 1024 * 1024
+)
+
+// uploadScript uploads the result files given as arguments to the Receiver of the service
+const uploadScript = `import os, sys, urllib.parse, urllib.request
+for name in sys.argv[1:]:
+    if not os.path.isfile(name):
+        continue
+    try:
+        with open(name, "rb") as content:
+            request = urllib.request.Request(
+                os.environ["LOCUST_SERVICE_RESULTS_URL"] + "/" + urllib.parse.quote(name), data=content, method="PUT",
+                headers={"Authorization": "Bearer " + os.environ["LOCUST_SERVICE_RESULTS_TOKEN"],
+                         "Content-Length": str(os.path.getsize(name))})
+            urllib.request.urlopen(request, timeout=120).close()
+    except Exception as e:
+        print("Could not upload %s: %s" % (name, e), file=sys.stderr)
+`
+
+// Spec describes a single locust run executed as Kubernetes job
+type Spec struct {
+	// Name is used for the job as well as for the ConfigMap holding the scripts, see JobName
+	Name string
+	// Namespace the job is created in
+	Namespace string
+	// WorkDir is the local directory containing the scripts of the run, and nothing else, as all of its files are
+	// shipped in the ConfigMap. It is recreated at the same path inside the job, so the locust arguments can refer
+	// to it, and the result files are uploaded back into it.
+	WorkDir string
+	// Args are the arguments locust is called with
+	Args []string
+	// SecretName is the secret whose data is passed to locust as environment variables (optional)
+	SecretName string
+	// ResultFiles are the files (relative to WorkDir) uploaded by the job after locust has finished
+	ResultFiles []string
+	// Labels are added to the job, its pod and the ConfigMap
+	Labels map[string]string
+	// Output receives the logs of locust while the job is running (optional)
+	Output io.Writer
+}
+
+// JobFailedError is returned if the locust job did not complete successfully
+type JobFailedError struct {
+	Job      string
+	ExitCode int
+	Output   string
+}
+
+func (e *JobFailedError) Error() string {
+	return fmt.Sprintf("Job %s failed with exit code %d\n%s", e.Job, e.ExitCode, e.Output)
+}
+
+// Runner executes locust runs as Kubernetes jobs
+type Runner struct {
+	Client kubernetes.Interface
+	Image  string
+	// PollInterval defines how often the status of the job is checked
+	PollInterval time.Duration
+	// ResultsURL is the base URL of the service under which the jobs reach the Receiver, e.g. http://10.0.0.1:8090
+	ResultsURL string
+	// Receiver takes the result files uploaded by the jobs, it has to be served at ResultsPath of the ResultsURL
+	Receiver *Receiver
+	// GracePeriod is the time locust gets to write its results after the job has been stopped
+	GracePeriod time.Duration
+}
+
+// NewRunner creates a new Runner whose jobs upload their results to the given URL
+func NewRunner(client kubernetes.Interface, image string, resultsURL string) *Runner {
+	return &Runner{
+		Client:       client,
+		Image:        image,
+		PollInterval: 5 * time.Second,
+		ResultsURL:   strings.TrimRight(resultsURL, "/"),
+		Receiver:     NewReceiver(),
+		GracePeriod:  30 * time.Second,
+	}
+}
+
+var invalidNameChars = regexp.MustCompile("[^a-z0-9-]+")
+
+// JobName derives a valid job name from the given id (e.g., the id of the triggering event)
+func JobName(id string) string {
+	name := "locust-" + invalidNameChars.ReplaceAllString(strings.ToLower(id), "-")
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return strings.TrimRight(name, "-")
+}
+
+// Run creates the ConfigMap and the job for the given spec and waits until the job has finished. The logs of locust
+// are streamed into the Output of the spec, the job uploads the result files into the WorkDir before it finishes.
+// If the context is done, the job is stopped and its pod gets the GracePeriod to upload the results collected so far.
+// The job and the ConfigMap are deleted afterwards.
+func (r *Runner) Run(ctx context.Context, spec Spec) error {
+	if r.ResultsURL == "" || r.Receiver == nil {
+		return errors.New("no URL configured to receive the results of the job")
+	}
+
+	configMap, items, err := newConfigMap(spec)
+	if err != nil {
+		return fmt.Errorf("could not read the scripts of %s: %w", spec.WorkDir, err)
+	}
+
+	token, err := r.Receiver.Expect(spec.Name, spec.WorkDir, spec.ResultFiles)
+	if err != nil {
+		return err
+	}
+	defer r.Receiver.Forget(spec.Name)
+
+	configMaps := r.Client.CoreV1().ConfigMaps(spec.Namespace)
+	if _, err := configMaps.Create(ctx, configMap, metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("could not create ConfigMap %s: %w", spec.Name, err)
+	}
+	defer func() {
+		if err := configMaps.Delete(context.TODO(), spec.Name, metav1.DeleteOptions{}); err != nil && !k8serrors.IsNotFound(err) {
+			log.Printf("Could not delete ConfigMap %s: %s", spec.Name, err.Error())
+		}
+	}()
+
+	jobs := r.Client.BatchV1().Jobs(spec.Namespace)
+	if _, err := jobs.Create(ctx, r.newJob(spec, configMap, items, token), metav1.CreateOptions{}); err != nil {
+		return fmt.Errorf("could not create job %s: %w", spec.Name, err)
+	}
+	defer func() {
+		propagation := metav1.DeletePropagationBackground
+		err := jobs.Delete(context.TODO(), spec.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			log.Printf("Could not delete job %s: %s", spec.Name, err.Error())
+		}
+	}()
+
+	log.Printf("Created job %s in namespace %s", spec.Name, spec.Namespace)
+
+	logs := &logStream{runner: r, spec: spec}
+	defer logs.wait()
+
+	var job *batchv1.Job
+	err = wait.PollImmediateUntil(r.PollInterval, func() (bool, error) {
+		job, err = jobs.Get(ctx, spec.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		logs.start(ctx)
+		return job.Status.Succeeded > 0 || job.Status.Failed > 0, nil
+	}, ctx.Done())
+	if ctx.Err() != nil {
+		r.stop(spec)
+		return fmt.Errorf("could not wait for job %s: %w", spec.Name, ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("could not wait for job %s: %w", spec.Name, err)
+	}
+	// the logs of a container that terminated between two polls are read at once
+	logs.start(context.Background())
+
+	pod, err := r.getPod(context.Background(), spec)
+	if err != nil {
+		return err
+	}
+	if job.Status.Succeeded == 0 {
+		return &JobFailedError{Job: spec.Name, ExitCode: exitCode(pod)}
+	}
+	return nil
+}
+
+// stop deletes the job together with its pod, so locust receives a SIGTERM and uploads the results collected so far,
+// and waits until the pod is gone
+func (r *Runner) stop(spec Spec) {
+	jobs := r.Client.BatchV1().Jobs(spec.Namespace)
+	propagation := metav1.DeletePropagationForeground
+	err := jobs.Delete(context.TODO(), spec.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			log.Printf("Could not stop job %s: %s", spec.Name, err.Error())
+		}
+		return
+	}
+
+	// with foreground deletion the job exists until its pod has been removed
+	ctx, cancel := context.WithTimeout(context.Background(), r.GracePeriod+stopMargin)
+	defer cancel()
+	err = wait.PollImmediateUntil(r.PollInterval, func() (bool, error) {
+		_, err := jobs.Get(ctx, spec.Name, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, nil
+	}, ctx.Done())
+	if err != nil {
+		log.Printf("Job %s has not stopped within %s, its results may be missing", spec.Name, r.GracePeriod+stopMargin)
+	}
+}
+
+// logStream copies the logs of the locust container into the Output of the spec once the container has started
+type logStream struct {
+	runner *Runner
+	spec   Spec
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// start starts following the logs if the container has started and they are not followed yet
+func (l *logStream) start(ctx context.Context) {
+	if l.done != nil || l.spec.Output == nil {
+		return
+	}
+	pod, err := l.runner.getPod(ctx, l.spec)
+	if err != nil || !started(pod) {
+		return
+	}
+
+	var streamCtx context.Context
+	streamCtx, l.cancel = context.WithCancel(context.Background())
+	l.done = make(chan struct{})
+	go func() {
+		defer close(l.done)
+		request := l.runner.Client.CoreV1().Pods(l.spec.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{Container: ContainerName, Follow: true})
+		stream, err := request.Stream(streamCtx)
+		if err != nil {
+			log.Printf("Could not get the logs of pod %s: %s", pod.Name, err.Error())
+			return
+		}
+		defer stream.Close()
+		if _, err := io.Copy(l.spec.Output, stream); err != nil && streamCtx.Err() == nil {
+			log.Printf("Could not read the logs of pod %s: %s", pod.Name, err.Error())
+		}
+	}()
+}
+
+// wait waits until the logs have been read to the end, at most for the logTimeout
+func (l *logStream) wait() {
+	if l.done == nil {
+		return
+	}
+	defer l.cancel()
+
+	timer := time.NewTimer(logTimeout)
+	defer timer.Stop()
+	select {
+	case <-l.done:
+	case <-timer.C:
+		log.Printf("Stopped reading the logs of job %s after %s", l.spec.Name, logTimeout)
+	}
+}
+
+// started returns true if the locust container of the pod is running or has terminated
+func started(pod *corev1.Pod) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == ContainerName && (status.State.Running != nil || status.State.Terminated != nil) {
+			return true
+		}
+	}
+	return false
+}
+
+// getPod returns the pod created for the job of the given spec
+func (r *Runner) getPod(ctx context.Context, spec Spec) (*corev1.Pod, error) {
+	pods, err := r.Client.CoreV1().Pods(spec.Namespace).List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + spec.Name})
+	if err != nil {
+		return nil, fmt.Errorf("could not list the pods of job %s: %w", spec.Name, err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no pod found for job %s", spec.Name)
+	}
+	return &pods.Items[len(pods.Items)-1], nil
+}
+
+// exitCode returns the exit code of the locust container or -1 if it has not terminated
+func exitCode(pod *corev1.Pod) int {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == ContainerName && status.State.Terminated != nil {
+			return int(status.State.Terminated.ExitCode)
+		}
+	}
+	return -1
+}
+
+// newConfigMap creates a ConfigMap containing every file of the WorkDir except for the result files (e.g. of a
+// previous run), as well as the items restoring the files at their relative path when the ConfigMap is mounted.
+// It fails if the files exceed MaxScriptsSize.
+func newConfigMap(spec Spec) (*corev1.ConfigMap, []corev1.KeyToPath, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      spec.Name,
+			Namespace: spec.Namespace,
+			Labels:    spec.Labels,
+		},
+		Data:       map[string]string{},
+		BinaryData: map[string][]byte{},
+	}
+	items := []corev1.KeyToPath{}
+	results := map[string]bool{}
+	for _, name := range spec.ResultFiles {
+		results[filepath.Clean(name)] = true
+	}
+	size := 0
+
+	err := filepath.Walk(spec.WorkDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(spec.WorkDir, path)
+		if err != nil {
+			return err
+		}
+		if results[relative] {
+			return nil
+		}
+		size += int(info.Size())
+		if size > MaxScriptsSize {
+			return fmt.Errorf("the scripts exceed the limit of %d bytes of a ConfigMap", MaxScriptsSize)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		// keys of a ConfigMap are restricted, so the files are numbered and their path is restored by the items
+		key := fmt.Sprintf("file-%d", len(items))
+		if utf8.Valid(content) {
+			configMap.Data[key] = string(content)
+		} else {
+			configMap.BinaryData[key] = content
+		}
+		items = append(items, corev1.KeyToPath{Key: key, Path: filepath.ToSlash(relative)})
+		return nil
+	})
+	return configMap, items, err
+}
+
+// newJob creates the job running locust with the scripts of the given ConfigMap
+func (r *Runner) newJob(spec Spec, configMap *corev1.ConfigMap, items []corev1.KeyToPath, token string) *batchv1.Job {
+	container := corev1.Container{
+		Name:       ContainerName,
+		Image:      r.Image,
+		WorkingDir: spec.WorkDir,
+		Command:    append([]string{"sh", "-c", runScript(spec.ResultFiles), "locust"}, spec.Args...),
+		// the variables of the secret are added after these ones, so they cannot replace them
+		Env: []corev1.EnvVar{
+			{Name: "LOCUST_SERVICE_RESULTS_URL", Value: r.ResultsURL + ResultsPath + spec.Name},
+			{Name: "LOCUST_SERVICE_RESULTS_TOKEN", Value: token},
+			{Name: "LOCUST_SERVICE_UPLOAD", Value: uploadScript},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: workVolume, MountPath: spec.WorkDir},
+		},
+	}
+	if spec.SecretName != "" {
+		optional := true
+		container.EnvFrom = []corev1.EnvFromSource{{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: spec.SecretName},
+				Optional:             &optional,
+			},
+		}}
+	}
+
+	backoffLimit := int32(0)
+	gracePeriod := int64(r.GracePeriod.Seconds())
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      spec.Name,
+			Namespace: spec.Namespace,
+			Labels:    spec.Labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: spec.Labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                 corev1.RestartPolicyNever,
+					TerminationGracePeriodSeconds: &gracePeriod,
+					// the ConfigMap is read-only, so the scripts are copied into a writable directory locust can write its results to
+					InitContainers: []corev1.Container{{
+						Name:    "prepare",
+						Image:   r.Image,
+						Command: []string{"sh", "-c", fmt.Sprintf("cp -rL %s/. '%s'", scriptsPath, spec.WorkDir)},
+						VolumeMounts: []corev1.VolumeMount{
+							{Name: scriptsVolume, MountPath: scriptsPath, ReadOnly: true},
+							{Name: workVolume, MountPath: spec.WorkDir},
+						},
+					}},
+					Containers: []corev1.Container{container},
+					Volumes: []corev1.Volume{
+						{
+							Name: scriptsVolume,
+							VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+								LocalObjectReference: corev1.LocalObjectReference{Name: configMap.Name},
+								Items:                items,
+							}},
+						},
+						{
+							Name:         workVolume,
+							VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// runScript runs locust with the arguments of the script and uploads the result files afterwards. A SIGTERM (e.g.
+// when the job is deleted) is passed to locust, so it stops and the results collected so far are uploaded as well.
+func runScript(resultFiles []string) string {
+	files := ""
+	for _, file := range resultFiles {
+		files += fmt.Sprintf(" '%s'", file)
+	}
+	return `locust "$@" &
+pid=$!
+trap 'kill -TERM $pid' TERM INT
+wait $pid
+code=$?
+# wait returns early if a signal has been trapped
+while kill -0 $pid 2>/dev/null; do wait $pid; code=$?; done
+python3 -c "$LOCUST_SERVICE_UPLOAD"` + files + `
+exit $code
+`
+}
@@ -0,0 +1,202 @@
+package kubejob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+const namespace = "keptn"
+
+func createWorkDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "kubejob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "locust"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "locust", "load.py"), []byte("from locust import HttpUser"), 0644))
+	return dir
+}
+
+// completeJob waits for the job of the runner, creates its pod and marks both as finished with the given exit code
+func completeJob(t *testing.T, kubernetes *k8sfake.Clientset, name string, code int32) *batchv1.Job {
+	var job *batchv1.Job
+	assert.Eventually(t, func() bool {
+		var err error
+		job, err = kubernetes.BatchV1().Jobs(namespace).Get(context.TODO(), name, metav1.GetOptions{})
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-abcde", Namespace: namespace, Labels: map[string]string{"job-name": name}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  ContainerName,
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: code}},
+		}}},
+	}
+	_, err := kubernetes.CoreV1().Pods(namespace).Create(context.TODO(), pod, metav1.CreateOptions{})
+	assert.NoError(t, err)
+
+	finished := job.DeepCopy()
+	if code == 0 {
+		finished.Status.Succeeded = 1
+	} else {
+		finished.Status.Failed = 1
+	}
+	_, err = kubernetes.BatchV1().Jobs(namespace).UpdateStatus(context.TODO(), finished, metav1.UpdateOptions{})
+	assert.NoError(t, err)
+	return job
+}
+
+// jobEnv returns the value of an environment variable of the locust container
+func jobEnv(job *batchv1.Job, name string) string {
+	for _, env := range job.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+func TestRun(t *testing.T) {
+	kubernetes := k8sfake.NewSimpleClientset()
+	runner := NewRunner(kubernetes, DefaultImage, "http://10.0.0.1:8090/")
+	runner.PollInterval = 5 * time.Millisecond
+
+	dir := createWorkDir(t)
+	output := &bytes.Buffer{}
+	spec := Spec{
+		Name:        JobName("1234-ABCD"),
+		Namespace:   namespace,
+		WorkDir:     dir,
+		Args:        []string{"--headless", "-f=" + filepath.Join(dir, "locust", "load.py")},
+		SecretName:  "locust-project-stage-service",
+		ResultFiles: []string{"locust_stats.csv"},
+		Output:      output,
+	}
+
+	jobs := make(chan *batchv1.Job, 1)
+	go func() {
+		var configMap *corev1.ConfigMap
+		assert.Eventually(t, func() bool {
+			var err error
+			configMap, err = kubernetes.CoreV1().ConfigMaps(namespace).Get(context.TODO(), spec.Name, metav1.GetOptions{})
+			return err == nil
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, "from locust import HttpUser", configMap.Data["file-0"])
+
+		job, err := kubernetes.BatchV1().Jobs(namespace).Get(context.TODO(), spec.Name, metav1.GetOptions{})
+		if assert.NoError(t, err) {
+			// the job uploads its results before it finishes
+			assert.Equal(t, http.StatusNoContent, putResult(runner.Receiver, ResultsPath+spec.Name+"/locust_stats.csv", jobEnv(job, "LOCUST_SERVICE_RESULTS_TOKEN"), "Type,Name\n"))
+		}
+		jobs <- completeJob(t, kubernetes, spec.Name, 0)
+	}()
+
+	assert.NoError(t, runner.Run(context.TODO(), spec))
+	// the fake clientset always returns "fake logs" as logs of a pod
+	assert.Equal(t, "fake logs", output.String())
+	content, err := ioutil.ReadFile(filepath.Join(dir, "locust_stats.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Type,Name\n", string(content))
+
+	job := <-jobs
+	assert.Equal(t, "locust-1234-abcd", job.Name)
+	container := job.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, DefaultImage, container.Image)
+	assert.Equal(t, dir, container.WorkingDir)
+	assert.Equal(t, spec.Args, container.Command[4:])
+	assert.Contains(t, container.Command[2], "'locust_stats.csv'")
+	assert.Equal(t, "http://10.0.0.1:8090/results/locust-1234-abcd", jobEnv(job, "LOCUST_SERVICE_RESULTS_URL"))
+	assert.Equal(t, "locust-project-stage-service", container.EnvFrom[0].SecretRef.Name)
+	assert.Equal(t, int64(30), *job.Spec.Template.Spec.TerminationGracePeriodSeconds)
+	items := job.Spec.Template.Spec.Volumes[0].ConfigMap.Items
+	assert.Equal(t, []corev1.KeyToPath{{Key: "file-0", Path: "locust/load.py"}}, items)
+
+	// the job cannot upload results after the run
+	assert.Equal(t, http.StatusUnauthorized, putResult(runner.Receiver, ResultsPath+spec.Name+"/locust_stats.csv", jobEnv(job, "LOCUST_SERVICE_RESULTS_TOKEN"), ""))
+
+	// job and ConfigMap are removed after the run
+	_, err = kubernetes.BatchV1().Jobs(namespace).Get(context.TODO(), spec.Name, metav1.GetOptions{})
+	assert.Error(t, err)
+	_, err = kubernetes.CoreV1().ConfigMaps(namespace).Get(context.TODO(), spec.Name, metav1.GetOptions{})
+	assert.Error(t, err)
+}
+
+func TestRun_JobFailed(t *testing.T) {
+	kubernetes := k8sfake.NewSimpleClientset()
+	runner := NewRunner(kubernetes, DefaultImage, "http://10.0.0.1:8090")
+	runner.PollInterval = 5 * time.Millisecond
+
+	spec := Spec{Name: JobName("failed"), Namespace: namespace, WorkDir: createWorkDir(t)}
+	go completeJob(t, kubernetes, spec.Name, 2)
+
+	err := runner.Run(context.TODO(), spec)
+	assert.Error(t, err)
+	jobErr, ok := err.(*JobFailedError)
+	if assert.True(t, ok) {
+		assert.Equal(t, 2, jobErr.ExitCode)
+	}
+}
+
+func TestRun_Canceled(t *testing.T) {
+	kubernetes := k8sfake.NewSimpleClientset()
+	runner := NewRunner(kubernetes, DefaultImage, "http://10.0.0.1:8090")
+	runner.PollInterval = 5 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	spec := Spec{Name: JobName("canceled"), Namespace: namespace, WorkDir: createWorkDir(t)}
+	err := runner.Run(ctx, spec)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// the job has been stopped
+	_, err = kubernetes.BatchV1().Jobs(namespace).Get(context.TODO(), spec.Name, metav1.GetOptions{})
+	assert.True(t, k8serrors.IsNotFound(err))
+}
+
+func TestRun_NoResultsURL(t *testing.T) {
+	runner := NewRunner(k8sfake.NewSimpleClientset(), DefaultImage, "")
+	assert.Error(t, runner.Run(context.TODO(), Spec{Name: JobName("no-url"), Namespace: namespace, WorkDir: createWorkDir(t)}))
+}
+
+func TestNewConfigMap(t *testing.T) {
+	dir := createWorkDir(t)
+	// result files of a previous run are not shipped to the job
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "locust_stats.csv"), []byte("Type,Name\n"), 0644))
+
+	configMap, items, err := newConfigMap(Spec{Name: "locust-abc", Namespace: namespace, WorkDir: dir, ResultFiles: []string{"locust_stats.csv"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []corev1.KeyToPath{{Key: "file-0", Path: "locust/load.py"}}, items)
+	assert.Equal(t, map[string]string{"file-0": "from locust import HttpUser"}, configMap.Data)
+}
+
+func TestNewConfigMap_TooLarge(t *testing.T) {
+	dir := createWorkDir(t)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "locust", "data.csv"), bytes.Repeat([]byte("a"), MaxScriptsSize), 0644))
+
+	_, _, err := newConfigMap(Spec{Name: "locust-abc", Namespace: namespace, WorkDir: dir})
+	assert.Error(t, err)
+}
+
+func TestJobName(t *testing.T) {
+	assert.Equal(t, "locust-3e7f1c2a-5d4b-4c8e-9f6a-1b2c3d4e5f60", JobName("3e7f1c2a-5d4b-4c8e-9f6a-1b2c3d4e5f60"))
+	assert.Equal(t, "locust-my-event", JobName("My_Event"))
+	assert.True(t, len(JobName("0123456789012345678901234567890123456789012345678901234567890123456789")) <= 63)
+}
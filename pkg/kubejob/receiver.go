@@ -0,0 +1,124 @@
+package kubejob
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ResultsPath is the path under which the jobs upload their result files, followed by the job name and the file
+const ResultsPath = "/results/"
+
+// upload holds the result files a job is allowed to upload and where they are written to
+type upload struct {
+	token string
+	dir   string
+	files map[string]bool
+}
+
+// Receiver accepts the result files uploaded by the locust jobs and writes them into the WorkDir of the run. Every
+// job authenticates with its own token, it can only upload the ResultFiles of its spec. It is safe for concurrent use.
+type Receiver struct {
+	mutex   sync.Mutex
+	uploads map[string]*upload
+}
+
+// NewReceiver creates a Receiver not expecting any uploads
+func NewReceiver() *Receiver {
+	return &Receiver{
+		uploads: map[string]*upload{},
+	}
+}
+
+// Expect accepts the given files of the job until Forget is called and returns the token the job has to send
+func (r *Receiver) Expect(job string, dir string, files []string) (string, error) {
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("could not create token: %w", err)
+	}
+
+	expected := &upload{token: hex.EncodeToString(random), dir: dir, files: map[string]bool{}}
+	for _, file := range files {
+		expected.files[file] = true
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.uploads[job] = expected
+	return expected.token, nil
+}
+
+// Forget rejects further uploads of the job
+func (r *Receiver) Forget(job string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.uploads, job)
+}
+
+// ServeHTTP handles PUT <ResultsPath><job>/<file> with the token of the job as bearer token
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPut {
+		w.Header().Set("Allow", http.MethodPut)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, ResultsPath), "/", 2)
+	if len(parts) != 2 {
+		http.NotFound(w, req)
+		return
+	}
+	job, file := parts[0], parts[1]
+
+	r.mutex.Lock()
+	expected, ok := r.uploads[job]
+	r.mutex.Unlock()
+
+	token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(expected.token)) != 1 {
+		http.Error(w, "unknown job or invalid token", http.StatusUnauthorized)
+		return
+	}
+	if !expected.files[file] {
+		http.NotFound(w, req)
+		return
+	}
+
+	if err := writeFile(filepath.Join(expected.dir, file), req.Body); err != nil {
+		log.Printf("Could not receive result file %s of job %s: %s", file, job, err.Error())
+		http.Error(w, "could not write file", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeFile streams the content into a temporary file that replaces the file once it is complete, so an interrupted
+// upload does not leave a truncated result file
+func writeFile(path string, content io.Reader) error {
+	temp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".upload-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := io.Copy(temp, content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(temp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,45 @@
+package kubejob
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func putResult(receiver *Receiver, path string, token string, content string) int {
+	request := httptest.NewRequest(http.MethodPut, path, strings.NewReader(content))
+	request.Header.Set("Authorization", "Bearer "+token)
+	recorder := httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, request)
+	return recorder.Code
+}
+
+func TestReceiver(t *testing.T) {
+	dir := createWorkDir(t)
+	receiver := NewReceiver()
+	token, err := receiver.Expect("locust-1", dir, []string{"locust_stats.csv"})
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusNoContent, putResult(receiver, ResultsPath+"locust-1/locust_stats.csv", token, "Type,Name\n"))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "locust_stats.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Type,Name\n", string(content))
+
+	// only the expected files of the job are accepted
+	assert.Equal(t, http.StatusNotFound, putResult(receiver, ResultsPath+"locust-1/locust/load.py", token, "import os"))
+	assert.Equal(t, http.StatusNotFound, putResult(receiver, ResultsPath+"locust-1/../locust_stats.csv", token, ""))
+	assert.Equal(t, http.StatusUnauthorized, putResult(receiver, ResultsPath+"locust-1/locust_stats.csv", "invalid", ""))
+	assert.Equal(t, http.StatusUnauthorized, putResult(receiver, ResultsPath+"locust-2/locust_stats.csv", token, ""))
+
+	receiver.Forget("locust-1")
+	assert.Equal(t, http.StatusUnauthorized, putResult(receiver, ResultsPath+"locust-1/locust_stats.csv", token, ""))
+
+	recorder := httptest.NewRecorder()
+	receiver.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, ResultsPath+"locust-1/locust_stats.csv", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
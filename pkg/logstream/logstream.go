@@ -0,0 +1,130 @@
+package logstream
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+)
+
+const (
+	// DefaultLimit is the number of bytes a Ring keeps if nothing else is configured
+	DefaultLimit = 64 * 1024
+	// MaxLineLength is the length after which a line without line break is passed on anyway
+	MaxLineLength = 16 * 1024
+)
+
+// Ring is an io.Writer keeping only the last bytes written to it, so the output of a long running process
+// can be kept for error messages without growing without bounds. It is safe for concurrent use.
+type Ring struct {
+	mutex   sync.Mutex
+	limit   int
+	data    []byte
+	dropped int64
+}
+
+// NewRing creates a Ring keeping the last limit bytes (DefaultLimit if limit is not positive)
+func NewRing(limit int) *Ring {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return &Ring{
+		limit: limit,
+	}
+}
+
+// Write keeps the written bytes, dropping the oldest ones beyond the limit. It never fails.
+func (r *Ring) Write(p []byte) (int, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	written := len(p)
+	if len(p) > r.limit {
+		r.dropped += int64(len(p) - r.limit)
+		p = p[len(p)-r.limit:]
+	}
+	r.data = append(r.data, p...)
+
+	// the buffer is compacted once it holds twice the limit, so copying is amortized
+	if len(r.data) > 2*r.limit {
+		excess := len(r.data) - r.limit
+		r.dropped += int64(excess)
+		r.data = append(r.data[:0], r.data[excess:]...)
+	}
+	return written, nil
+}
+
+// Dropped returns the number of bytes that have been dropped so far
+func (r *Ring) Dropped() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.dropped + int64(r.excess())
+}
+
+// String returns the last bytes written, preceded by a note if older bytes have been dropped
+func (r *Ring) String() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	excess := r.excess()
+	if dropped := r.dropped + int64(excess); dropped > 0 {
+		return fmt.Sprintf("[... %d bytes omitted]\n%s", dropped, r.data[excess:])
+	}
+	return string(r.data)
+}
+
+// excess returns the number of bytes of the buffer beyond the limit
+func (r *Ring) excess() int {
+	if len(r.data) > r.limit {
+		return len(r.data) - r.limit
+	}
+	return 0
+}
+
+// LineWriter is an io.Writer passing every complete line to a function, e.g. to log it with a prefix.
+// Lines longer than MaxLineLength are passed on in parts. It is safe for concurrent use.
+type LineWriter struct {
+	mutex   sync.Mutex
+	handle  func(line string)
+	partial []byte
+}
+
+// NewLineWriter creates a LineWriter calling handle for every line (without line break)
+func NewLineWriter(handle func(line string)) *LineWriter {
+	return &LineWriter{
+		handle: handle,
+	}
+}
+
+// Write passes every completed line on and keeps the remainder until its line break is written
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.partial = append(w.partial, p...)
+	for {
+		index := bytes.IndexByte(w.partial, '\n')
+		if index < 0 {
+			break
+		}
+		w.handle(string(bytes.TrimSuffix(w.partial[:index], []byte("\r"))))
+		w.partial = w.partial[index+1:]
+	}
+	for len(w.partial) >= MaxLineLength {
+		w.handle(string(w.partial[:MaxLineLength]))
+		w.partial = w.partial[MaxLineLength:]
+	}
+	// the remainder is copied, so the underlying array of consumed lines can be freed
+	w.partial = append([]byte{}, w.partial...)
+	return len(p), nil
+}
+
+// Flush passes on the last line, even if it has no line break
+func (w *LineWriter) Flush() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.partial) > 0 {
+		w.handle(string(w.partial))
+		w.partial = nil
+	}
+}
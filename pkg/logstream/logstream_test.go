@@ -0,0 +1,67 @@
+package logstream
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing(10)
+	fmt.Fprint(ring, "hello")
+	assert.Equal(t, "hello", ring.String())
+	assert.Equal(t, int64(0), ring.Dropped())
+
+	fmt.Fprint(ring, " world!")
+	assert.Equal(t, int64(2), ring.Dropped())
+	assert.Equal(t, "[... 2 bytes omitted]\nllo world!", ring.String())
+
+	// writes larger than the limit only keep their end
+	fmt.Fprint(ring, strings.Repeat("a", 25)+"0123456789")
+	assert.Equal(t, "[... 37 bytes omitted]\n0123456789", ring.String())
+}
+
+func TestRing_Bounded(t *testing.T) {
+	ring := NewRing(100)
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(ring, "line %d\n", i)
+	}
+	assert.True(t, cap(ring.data) <= 1000)
+	assert.True(t, strings.HasSuffix(ring.String(), "line 9999\n"))
+}
+
+func TestLineWriter(t *testing.T) {
+	lines := []string{}
+	writer := NewLineWriter(func(line string) {
+		lines = append(lines, line)
+	})
+
+	fmt.Fprint(writer, "first line\nsecond ")
+	assert.Equal(t, []string{"first line"}, lines)
+
+	fmt.Fprint(writer, "line\r\nthird")
+	assert.Equal(t, []string{"first line", "second line"}, lines)
+
+	writer.Flush()
+	assert.Equal(t, []string{"first line", "second line", "third"}, lines)
+
+	writer.Flush()
+	assert.Len(t, lines, 3)
+}
+
+func TestLineWriter_LongLine(t *testing.T) {
+	lines := []string{}
+	writer := NewLineWriter(func(line string) {
+		lines = append(lines, line)
+	})
+
+	fmt.Fprint(writer, strings.Repeat("x", MaxLineLength+10))
+	assert.Len(t, lines, 1)
+	assert.Len(t, lines[0], MaxLineLength)
+
+	writer.Flush()
+	assert.Len(t, lines, 2)
+	assert.Len(t, lines[1], 10)
+}
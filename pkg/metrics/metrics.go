@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "locust_service"
+
+// Path is the path under which the metrics are served
+const Path = "/metrics"
+
+var targetLabels = []string{"project", "stage", "service"}
+var endpointLabels = []string{"project", "stage", "service", "endpoint"}
+
+// Metrics holds the Prometheus metrics of the service and the results of the last locust runs
+type Metrics struct {
+	registry *prometheus.Registry
+
+	runsStarted          *prometheus.CounterVec
+	runsFinished         *prometheus.CounterVec
+	runsErrored          *prometheus.CounterVec
+	runsAborted          *prometheus.CounterVec
+	runDuration          *prometheus.HistogramVec
+	configFetchFailures  prometheus.Counter
+	configParseFailures  prometheus.Counter
+	secretLookupFailures prometheus.Counter
+
+	requests     *prometheus.GaugeVec
+	failures     *prometheus.GaugeVec
+	failureRatio *prometheus.GaugeVec
+	rps          *prometheus.GaugeVec
+	responseTime *prometheus.GaugeVec
+
+	// endpoints remembers the endpoints of the last run per target, so they can be removed when a new run finishes
+	mutex     sync.Mutex
+	endpoints map[[3]string][]string
+}
+
+// New creates and registers all metrics in a new registry
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		runsStarted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "runs_started_total", Help: "Number of started locust runs",
+		}, targetLabels),
+		runsFinished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "runs_finished_total", Help: "Number of finished locust runs by result",
+		}, append(targetLabels, "result")),
+		runsErrored: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "runs_errored_total", Help: "Number of locust runs that could not be completed",
+		}, targetLabels),
+		runsAborted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "runs_aborted_total", Help: "Number of locust runs that have been canceled",
+		}, targetLabels),
+		runDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Name: "run_duration_seconds", Help: "Duration of the locust runs",
+			Buckets: prometheus.ExponentialBuckets(10, 2, 10),
+		}, targetLabels),
+		configFetchFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "config_fetch_failures_total", Help: "Number of resources that could not be fetched from the config repo",
+		}),
+		configParseFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "config_parse_failures_total", Help: "Number of configuration files that are invalid",
+		}),
+		secretLookupFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "secret_lookup_failures_total", Help: "Number of secrets that could not be read",
+		}),
+		requests: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "last_run_requests", Help: "Number of requests of the last locust run",
+		}, endpointLabels),
+		failures: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "last_run_failures", Help: "Number of failed requests of the last locust run",
+		}, endpointLabels),
+		failureRatio: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "last_run_failure_ratio", Help: "Share of failed requests of the last locust run",
+		}, endpointLabels),
+		rps: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "last_run_requests_per_second", Help: "Requests per second of the last locust run",
+		}, endpointLabels),
+		responseTime: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Name: "last_run_response_time_milliseconds", Help: "Response time percentiles of the last locust run",
+		}, append(endpointLabels, "quantile")),
+		endpoints: map[[3]string][]string{},
+	}
+
+	m.registry.MustRegister(
+		m.runsStarted, m.runsFinished, m.runsErrored, m.runsAborted, m.runDuration, m.configFetchFailures, m.configParseFailures,
+		m.secretLookupFailures,
+		m.requests, m.failures, m.failureRatio, m.rps, m.responseTime,
+	)
+	return m
+}
+
+// Handler returns the HTTP handler serving the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// RunStarted counts a started locust run
+func (m *Metrics) RunStarted(project string, stage string, service string) {
+	m.runsStarted.WithLabelValues(project, stage, service).Inc()
+}
+
+// RunFinished counts a finished locust run and observes its duration
+func (m *Metrics) RunFinished(project string, stage string, service string, result string, duration time.Duration) {
+	m.runsFinished.WithLabelValues(project, stage, service, result).Inc()
+	m.runDuration.WithLabelValues(project, stage, service).Observe(duration.Seconds())
+}
+
+// RunErrored counts a locust run that could not be completed and observes its duration
+func (m *Metrics) RunErrored(project string, stage string, service string, duration time.Duration) {
+	m.runsErrored.WithLabelValues(project, stage, service).Inc()
+	m.runDuration.WithLabelValues(project, stage, service).Observe(duration.Seconds())
+}
+
+// RunAborted counts a canceled locust run and observes its duration
+func (m *Metrics) RunAborted(project string, stage string, service string, duration time.Duration) {
+	m.runsAborted.WithLabelValues(project, stage, service).Inc()
+	m.runDuration.WithLabelValues(project, stage, service).Observe(duration.Seconds())
+}
+
+// ConfigFetchFailed counts a resource that could not be fetched from the config repo
+func (m *Metrics) ConfigFetchFailed() {
+	m.configFetchFailures.Inc()
+}
+
+// ConfigParseFailed counts a configuration file that has been fetched but could not be parsed or is invalid
+func (m *Metrics) ConfigParseFailed() {
+	m.configParseFailures.Inc()
+}
+
+// SecretLookupFailed counts a secret that could not be read
+func (m *Metrics) SecretLookupFailed() {
+	m.secretLookupFailures.Inc()
+}
+
+// RecordStatistics replaces the results of the last run of the given project, stage and service
+func (m *Metrics) RecordStatistics(project string, stage string, service string, statistics *stats.Statistics) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	target := [3]string{project, stage, service}
+	for _, endpoint := range m.endpoints[target] {
+		m.deleteEndpoint(project, stage, service, endpoint)
+	}
+
+	endpoints := []string{}
+	for _, requestStats := range append([]stats.RequestStats{statistics.Aggregated}, statistics.Endpoints...) {
+		endpoint := requestStats.Name
+		if requestStats.Type != "" {
+			endpoint = requestStats.Type + " " + requestStats.Name
+		}
+		endpoints = append(endpoints, endpoint)
+
+		m.requests.WithLabelValues(project, stage, service, endpoint).Set(float64(requestStats.RequestCount))
+		m.failures.WithLabelValues(project, stage, service, endpoint).Set(float64(requestStats.FailureCount))
+		m.failureRatio.WithLabelValues(project, stage, service, endpoint).Set(requestStats.FailureRatio())
+		m.rps.WithLabelValues(project, stage, service, endpoint).Set(requestStats.RequestsPerSecond)
+		m.responseTime.WithLabelValues(project, stage, service, endpoint, "0.5").Set(requestStats.P50)
+		m.responseTime.WithLabelValues(project, stage, service, endpoint, "0.9").Set(requestStats.P90)
+		m.responseTime.WithLabelValues(project, stage, service, endpoint, "0.95").Set(requestStats.P95)
+		m.responseTime.WithLabelValues(project, stage, service, endpoint, "0.99").Set(requestStats.P99)
+	}
+	m.endpoints[target] = endpoints
+}
+
+func (m *Metrics) deleteEndpoint(project string, stage string, service string, endpoint string) {
+	m.requests.DeleteLabelValues(project, stage, service, endpoint)
+	m.failures.DeleteLabelValues(project, stage, service, endpoint)
+	m.failureRatio.DeleteLabelValues(project, stage, service, endpoint)
+	m.rps.DeleteLabelValues(project, stage, service, endpoint)
+	for _, quantile := range []string{"0.5", "0.9", "0.95", "0.99"} {
+		m.responseTime.DeleteLabelValues(project, stage, service, endpoint, quantile)
+	}
+}
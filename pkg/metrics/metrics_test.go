@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, Path, nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	return recorder.Body.String()
+}
+
+func TestRunCounters(t *testing.T) {
+	m := New()
+	m.RunStarted("sockshop", "dev", "carts")
+	m.RunFinished("sockshop", "dev", "carts", "pass", 2*time.Minute)
+	m.RunErrored("sockshop", "dev", "carts", time.Second)
+	m.RunAborted("sockshop", "dev", "carts", time.Second)
+	m.ConfigFetchFailed()
+	m.ConfigParseFailed()
+	m.SecretLookupFailed()
+
+	output := scrape(t, m)
+	assert.Contains(t, output, `locust_service_runs_started_total{project="sockshop",service="carts",stage="dev"} 1`)
+	assert.Contains(t, output, `locust_service_runs_finished_total{project="sockshop",result="pass",service="carts",stage="dev"} 1`)
+	assert.Contains(t, output, `locust_service_runs_errored_total{project="sockshop",service="carts",stage="dev"} 1`)
+	assert.Contains(t, output, `locust_service_runs_aborted_total{project="sockshop",service="carts",stage="dev"} 1`)
+	assert.Contains(t, output, `locust_service_run_duration_seconds_count{project="sockshop",service="carts",stage="dev"} 3`)
+	assert.Contains(t, output, `locust_service_config_fetch_failures_total 1`)
+	assert.Contains(t, output, `locust_service_config_parse_failures_total 1`)
+	assert.Contains(t, output, `locust_service_secret_lookup_failures_total 1`)
+}
+
+func TestRecordStatistics(t *testing.T) {
+	m := New()
+	m.RecordStatistics("sockshop", "dev", "carts", &stats.Statistics{
+		Endpoints:  []stats.RequestStats{{Type: "GET", Name: "/carts", RequestCount: 50, P95: 80}},
+		Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 150, RequestsPerSecond: 15},
+	})
+
+	output := scrape(t, m)
+	assert.Contains(t, output, `locust_service_last_run_requests{endpoint="GET /carts",project="sockshop",service="carts",stage="dev"} 50`)
+	assert.Contains(t, output, `locust_service_last_run_requests_per_second{endpoint="Aggregated",project="sockshop",service="carts",stage="dev"} 15`)
+	assert.Contains(t, output, `locust_service_last_run_response_time_milliseconds{endpoint="GET /carts",project="sockshop",quantile="0.95",service="carts",stage="dev"} 80`)
+
+	// endpoints of the previous run are removed
+	m.RecordStatistics("sockshop", "dev", "carts", &stats.Statistics{
+		Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 10},
+	})
+
+	output = scrape(t, m)
+	assert.NotContains(t, output, `endpoint="GET /carts"`)
+	assert.Contains(t, output, `locust_service_last_run_requests{endpoint="Aggregated",project="sockshop",service="carts",stage="dev"} 10`)
+}
@@ -0,0 +1,50 @@
+package process
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// UseProcessGroup makes the command start in its own process group, so it can be signalled together with
+// every child process it spawns
+func UseProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// Signal sends the signal to the process group of a command started with UseProcessGroup.
+// Commands that have not been started or that have already exited are ignored.
+func Signal(cmd *exec.Cmd, signal syscall.Signal) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	err := syscall.Kill(-cmd.Process.Pid, signal)
+	if err == syscall.ESRCH {
+		return nil
+	}
+	return err
+}
+
+// Kill kills the process group of a command started with UseProcessGroup
+func Kill(cmd *exec.Cmd) error {
+	return Signal(cmd, syscall.SIGKILL)
+}
+
+// Terminate sends SIGTERM to the process group of the command, giving it the chance to shut down gracefully, and
+// kills it if it has not exited within the grace period. exited has to receive once the command has exited.
+func Terminate(cmd *exec.Cmd, gracePeriod time.Duration, exited <-chan error) {
+	Signal(cmd, syscall.SIGTERM)
+
+	timer := time.NewTimer(gracePeriod)
+	defer timer.Stop()
+
+	select {
+	case <-exited:
+	case <-timer.C:
+		Kill(cmd)
+		<-exited
+	}
+}
@@ -0,0 +1,71 @@
+package process
+
+import (
+	"bytes"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKill(t *testing.T) {
+	// the shell spawns a child process that would keep running if only the shell was killed
+	cmd := exec.Command("sh", "-c", "sleep 10; echo done")
+	UseProcessGroup(cmd)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+
+	assert.NoError(t, cmd.Start())
+	start := time.Now()
+	assert.NoError(t, Kill(cmd))
+	assert.Error(t, cmd.Wait())
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestSignal_NotStarted(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "exit 0")
+	assert.NoError(t, Signal(cmd, syscall.SIGTERM))
+}
+
+func TestSignal_AlreadyExited(t *testing.T) {
+	cmd := exec.Command("sh", "-c", "exit 0")
+	UseProcessGroup(cmd)
+	assert.NoError(t, cmd.Run())
+	assert.NoError(t, Signal(cmd, syscall.SIGTERM))
+}
+
+// start starts the shell script in its own process group, the returned channel receives once it has exited
+func start(t *testing.T, script string, output *bytes.Buffer) (*exec.Cmd, <-chan error) {
+	cmd := exec.Command("sh", "-c", script)
+	cmd.Stdout = output
+	UseProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	return cmd, exited
+}
+
+func TestTerminate_GracefulShutdown(t *testing.T) {
+	// the shell reports the termination like locust writes its statistics on SIGTERM
+	var output bytes.Buffer
+	cmd, exited := start(t, "trap 'echo terminated; exit 1' TERM; while true; do sleep 0.01; done", &output)
+	time.Sleep(100 * time.Millisecond)
+
+	Terminate(cmd, 5*time.Second, exited)
+	assert.Equal(t, "terminated\n", output.String())
+}
+
+func TestTerminate_KilledAfterGracePeriod(t *testing.T) {
+	cmd, exited := start(t, "trap '' TERM; while true; do sleep 0.01; done", &bytes.Buffer{})
+
+	begin := time.Now()
+	Terminate(cmd, 100*time.Millisecond, exited)
+	assert.True(t, time.Since(begin) < 5*time.Second)
+}
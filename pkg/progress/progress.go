@@ -0,0 +1,113 @@
+package progress
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+)
+
+// Progress is a snapshot of a running locust test
+type Progress struct {
+	Elapsed           time.Duration
+	Users             int64
+	RequestsPerSecond float64
+	FailureRatio      float64
+	TotalRequests     int64
+	TotalFailures     int64
+}
+
+func (p Progress) String() string {
+	return fmt.Sprintf("Locust test running for %s: users=%d, rps=%.2f, failure rate=%.2f%%, requests=%d, failures=%d",
+		p.Elapsed.Truncate(time.Second), p.Users, p.RequestsPerSecond, p.FailureRatio*100, p.TotalRequests, p.TotalFailures)
+}
+
+// Reporter periodically reads the stats history locust writes while it runs and reports the progress
+type Reporter struct {
+	// CSVPrefix is the --csv prefix locust has been started with
+	CSVPrefix string
+	// Interval defines how often the progress is reported
+	Interval time.Duration
+	// Report is called with the latest progress on every interval
+	Report func(progress Progress)
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewReporter creates a new Reporter
+func NewReporter(csvPrefix string, interval time.Duration, report func(progress Progress)) *Reporter {
+	return &Reporter{
+		CSVPrefix: csvPrefix,
+		Interval:  interval,
+		Report:    report,
+	}
+}
+
+// Start starts reporting in the background until Stop is called. A non-positive interval disables the reporting.
+func (r *Reporter) Start() {
+	if r.Interval <= 0 {
+		return
+	}
+
+	r.stop = make(chan struct{})
+	r.wg.Add(1)
+	go r.run(time.Now())
+}
+
+// Stop stops reporting and waits until the last report has been sent
+func (r *Reporter) Stop() {
+	if r.stop == nil {
+		return
+	}
+	close(r.stop)
+	r.wg.Wait()
+	r.stop = nil
+}
+
+func (r *Reporter) run(start time.Time) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case now := <-ticker.C:
+			// locust keeps appending to the file, so a read can fail on an incomplete line; the next tick will catch up
+			history, err := stats.ParseHistory(r.CSVPrefix)
+			if err != nil {
+				continue
+			}
+			if progress, ok := Latest(history, now.Sub(start)); ok {
+				r.Report(progress)
+			}
+		}
+	}
+}
+
+// Latest returns the progress described by the most recent aggregated entry of the stats history
+func Latest(history []stats.HistoryEntry, elapsed time.Duration) (Progress, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		entry := history[i]
+		if entry.Name != stats.AggregatedName {
+			continue
+		}
+
+		progress := Progress{
+			Elapsed:           elapsed,
+			Users:             entry.UserCount,
+			RequestsPerSecond: entry.RequestsPerSecond,
+			TotalRequests:     entry.TotalRequestCount,
+			TotalFailures:     entry.TotalFailureCount,
+		}
+		if entry.RequestsPerSecond > 0 {
+			progress.FailureRatio = entry.FailuresPerSecond / entry.RequestsPerSecond
+		}
+		return progress, true
+	}
+	return Progress{}, false
+}
@@ -0,0 +1,68 @@
+package progress
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+const historyCSV = `Timestamp,User Count,Type,Name,Requests/s,Failures/s,50%,66%,75%,80%,90%,95%,98%,99%,99.9%,99.99%,100%,Total Request Count,Total Failure Count
+1625000000,5,,Aggregated,10.0,0.0,15,16,18,20,40,60,80,100,120,120,120,50,0
+1625000010,10,,Aggregated,20.0,1.0,15,16,18,20,40,60,80,100,120,120,120,250,10
+`
+
+func TestLatest(t *testing.T) {
+	history := []stats.HistoryEntry{
+		{UserCount: 5, Name: stats.AggregatedName, RequestsPerSecond: 10},
+		{UserCount: 10, Name: stats.AggregatedName, RequestsPerSecond: 20, FailuresPerSecond: 1, TotalRequestCount: 250, TotalFailureCount: 10},
+		{UserCount: 10, Type: "GET", Name: "/carts", RequestsPerSecond: 5},
+	}
+
+	progress, ok := Latest(history, 90*time.Second)
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), progress.Users)
+	assert.Equal(t, 0.05, progress.FailureRatio)
+	assert.Equal(t, "Locust test running for 1m30s: users=10, rps=20.00, failure rate=5.00%, requests=250, failures=10", progress.String())
+
+	_, ok = Latest([]stats.HistoryEntry{}, time.Second)
+	assert.False(t, ok)
+}
+
+func TestReporter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "progress")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "locust_stats_history.csv"), []byte(historyCSV), 0644))
+
+	var mutex sync.Mutex
+	reports := []Progress{}
+	reporter := NewReporter(filepath.Join(dir, "locust"), 10*time.Millisecond, func(progress Progress) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		reports = append(reports, progress)
+	})
+
+	reporter.Start()
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(reports) > 0
+	}, time.Second, 10*time.Millisecond)
+	reporter.Stop()
+
+	assert.Equal(t, int64(250), reports[0].TotalRequests)
+}
+
+func TestReporter_Disabled(t *testing.T) {
+	reporter := NewReporter("unused", 0, func(progress Progress) {
+		t.Error("unexpected report")
+	})
+	reporter.Start()
+	reporter.Stop()
+}
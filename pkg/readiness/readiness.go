@@ -0,0 +1,135 @@
+package readiness
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultStatus is the status the target is expected to answer with if nothing else is configured
+	DefaultStatus = http.StatusOK
+	// DefaultTimeout is how long the target may take to become ready if nothing else is configured
+	DefaultTimeout = 2 * time.Minute
+	// DefaultInterval is the time between two probes if nothing else is configured
+	DefaultInterval = 5 * time.Second
+)
+
+// Config defines the probe checking that the target answers before the test is started
+type Config struct {
+	// Path is requested relative to the URL of the target (default: the URL itself)
+	Path string `json:"path" yaml:"path"`
+	// Status is the expected HTTP status code (default: 200)
+	Status int `json:"status" yaml:"status"`
+	// Timeout defines how long the target may take to become ready, e.g. 5m (default: 2m)
+	Timeout string `json:"timeout" yaml:"timeout"`
+	// Interval defines the time between two probes, e.g. 10s (default: 5s)
+	Interval string `json:"interval" yaml:"interval"`
+}
+
+// Validate checks the settings of the probe
+func (c *Config) Validate() error {
+	if c.Status != 0 && (c.Status < 100 || c.Status > 599) {
+		return fmt.Errorf("invalid status: %d", c.Status)
+	}
+	if c.Timeout != "" {
+		if timeout, err := time.ParseDuration(c.Timeout); err != nil || timeout <= 0 {
+			return fmt.Errorf("invalid timeout: %s", c.Timeout)
+		}
+	}
+	if c.Interval != "" {
+		if interval, err := time.ParseDuration(c.Interval); err != nil || interval <= 0 {
+			return fmt.Errorf("invalid interval: %s", c.Interval)
+		}
+	}
+	if _, err := url.Parse(c.Path); err != nil {
+		return fmt.Errorf("invalid path: %s", c.Path)
+	}
+	return nil
+}
+
+// ExpectedStatus returns the status the target has to answer with
+func (c *Config) ExpectedStatus() int {
+	if c.Status == 0 {
+		return DefaultStatus
+	}
+	return c.Status
+}
+
+// TimeoutDuration returns how long the target may take to become ready
+func (c *Config) TimeoutDuration() time.Duration {
+	return parseDuration(c.Timeout, DefaultTimeout)
+}
+
+// IntervalDuration returns the time between two probes
+func (c *Config) IntervalDuration() time.Duration {
+	return parseDuration(c.Interval, DefaultInterval)
+}
+
+func parseDuration(value string, defaultValue time.Duration) time.Duration {
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return defaultValue
+	}
+	return duration
+}
+
+// Wait probes the target until it answers with the expected status. It returns an error describing the last
+// failed probe if the target is not ready within the timeout, or the error of the context if it is done first.
+func Wait(ctx context.Context, target *url.URL, config *Config) error {
+	path, err := url.Parse(config.Path)
+	if err != nil {
+		return fmt.Errorf("invalid path: %s", config.Path)
+	}
+	probeURL := target.ResolveReference(path).String()
+
+	timeout := config.TimeoutDuration()
+	interval := config.IntervalDuration()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for {
+		err := probe(ctx, probeURL, config.ExpectedStatus(), interval)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return fmt.Errorf("no answer with status %d within %s, last probe: %w", config.ExpectedStatus(), timeout, err)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// probe requests the URL once, the request may take at most the given time
+func probe(ctx context.Context, probeURL string, status int, timeout time.Duration) error {
+	requestCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(requestCtx, http.MethodGet, probeURL, nil)
+	if err != nil {
+		return err
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	// read the body, so the connection can be reused by the next probe
+	io.Copy(ioutil.Discard, response.Body)
+
+	if response.StatusCode != status {
+		return fmt.Errorf("GET %s returned status %d", probeURL, response.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package readiness
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Config{}).Validate())
+	assert.NoError(t, (&Config{Path: "/health", Status: 204, Timeout: "5m", Interval: "10s"}).Validate())
+
+	assert.Error(t, (&Config{Status: 42}).Validate())
+	assert.Error(t, (&Config{Timeout: "soon"}).Validate())
+	assert.Error(t, (&Config{Timeout: "0s"}).Validate())
+	assert.Error(t, (&Config{Interval: "-1s"}).Validate())
+}
+
+func TestDefaults(t *testing.T) {
+	config := &Config{}
+	assert.Equal(t, DefaultStatus, config.ExpectedStatus())
+	assert.Equal(t, DefaultTimeout, config.TimeoutDuration())
+	assert.Equal(t, DefaultInterval, config.IntervalDuration())
+}
+
+func TestWait_Ready(t *testing.T) {
+	var probes int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// the target becomes ready with the third probe
+		if atomic.AddInt32(&probes, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL + "/carts")
+	err := Wait(context.Background(), target, &Config{Path: "/health", Status: http.StatusNoContent, Timeout: "5s", Interval: "10ms"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&probes))
+}
+
+func TestWait_NotReady(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	target, _ := url.Parse(server.URL)
+	start := time.Now()
+	err := Wait(context.Background(), target, &Config{Timeout: "100ms", Interval: "10ms"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "returned status 503")
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestWait_Canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	target, _ := url.Parse(server.URL)
+	err := Wait(ctx, target, &Config{Timeout: "1m", Interval: "10ms"})
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"context"
+	"sync"
+)
+
+type run struct {
+	keptnContext string
+	cancel       context.CancelFunc
+}
+
+// Registry keeps track of the queued and running locust runs, so they can be canceled
+type Registry struct {
+	mutex sync.Mutex
+	runs  map[string]*run
+}
+
+// New creates a new Registry
+func New() *Registry {
+	return &Registry{
+		runs: map[string]*run{},
+	}
+}
+
+// Register adds the run with the given id (the id of the test.triggered event) and returns its context, which is
+// canceled once the run is canceled. The returned function removes the run and has to be called when it is done.
+func (r *Registry) Register(parent context.Context, id string, keptnContext string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(parent)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.runs[id] = &run{keptnContext: keptnContext, cancel: cancel}
+
+	return ctx, func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		delete(r.runs, id)
+		cancel()
+	}
+}
+
+// Cancel cancels the run with the given id, it returns false if no such run is registered
+func (r *Registry) Cancel(id string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	run, ok := r.runs[id]
+	if !ok {
+		return false
+	}
+	run.cancel()
+	return true
+}
+
+// CancelKeptnContext cancels all runs of the given keptnContext and returns how many have been canceled
+func (r *Registry) CancelKeptnContext(keptnContext string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	canceled := 0
+	for _, run := range r.runs {
+		if run.keptnContext == keptnContext {
+			run.cancel()
+			canceled++
+		}
+	}
+	return canceled
+}
@@ -0,0 +1,39 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCancel(t *testing.T) {
+	registry := New()
+	ctx, done := registry.Register(context.Background(), "run-1", "context-1")
+
+	assert.False(t, registry.Cancel("unknown"))
+	assert.NoError(t, ctx.Err())
+
+	assert.True(t, registry.Cancel("run-1"))
+	assert.Equal(t, context.Canceled, ctx.Err())
+
+	done()
+	assert.False(t, registry.Cancel("run-1"))
+}
+
+func TestCancelKeptnContext(t *testing.T) {
+	registry := New()
+	ctx1, done1 := registry.Register(context.Background(), "run-1", "context-1")
+	defer done1()
+	ctx2, done2 := registry.Register(context.Background(), "run-2", "context-1")
+	defer done2()
+	ctx3, done3 := registry.Register(context.Background(), "run-3", "context-2")
+	defer done3()
+
+	assert.Equal(t, 2, registry.CancelKeptnContext("context-1"))
+	assert.Error(t, ctx1.Err())
+	assert.Error(t, ctx2.Err())
+	assert.NoError(t, ctx3.Err())
+
+	assert.Equal(t, 0, registry.CancelKeptnContext("unknown"))
+}
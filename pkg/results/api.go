@@ -0,0 +1,60 @@
+package results
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/keptn-sandbox/locust-service/pkg/httpjson"
+)
+
+// ReportsPath is the path under which the stored reports are served, it must not overlap with the path the results
+// of Kubernetes jobs are uploaded to
+const ReportsPath = "/reports"
+
+// Handler serves the stored reports and statistics of the tests via HTTP:
+//
+//	GET /reports/<keptnContext>/<stage>/<service>/<file> (e.g. junit.xml, report.html or statistics.json)
+type Handler struct {
+	Store *Store
+}
+
+// NewHandler creates a new Handler for the given store
+func NewHandler(store *Store) *Handler {
+	return &Handler{
+		Store: store,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpjson.Error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, ReportsPath), "/"), "/")
+	if len(parts) != 4 {
+		httpjson.Error(w, http.StatusNotFound, "expected /reports/<keptnContext>/<stage>/<service>/<file>")
+		return
+	}
+	key := Key{KeptnContext: parts[0], Stage: parts[1], Service: parts[2]}
+
+	content, err := h.Store.LoadArtifact(key, parts[3])
+	if os.IsNotExist(err) {
+		httpjson.Error(w, http.StatusNotFound, "no "+parts[3]+" stored for "+key.String())
+		return
+	} else if err != nil {
+		httpjson.Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(parts[3]))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(content)
+}
@@ -0,0 +1,39 @@
+package results
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	store := createStore(t)
+	assert.NoError(t, store.SaveArtifact(key("a1b2c3"), "junit.xml", []byte("<testsuites></testsuites>")))
+
+	handler := NewHandler(store)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/reports/a1b2c3/dev/carts/junit.xml", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "xml")
+	assert.Equal(t, "<testsuites></testsuites>", recorder.Body.String())
+
+	// the reports of other stages are kept apart
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/reports/a1b2c3/staging/carts/junit.xml", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/reports/a1b2c3/junit.xml", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/reports/../dev/carts/junit.xml", nil))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/reports/a1b2c3/dev/carts/junit.xml", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
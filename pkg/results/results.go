@@ -0,0 +1,164 @@
+package results
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+)
+
+// StatisticsFilename is the name of the file holding the parsed statistics of a run
+const StatisticsFilename = "statistics.json"
+
+// Key identifies the results of a test. A keptnContext spans all stages of a sequence, so the results of every stage
+// and service are kept apart.
+type Key struct {
+	KeptnContext string
+	Stage        string
+	Service      string
+}
+
+// String returns the key as keptnContext/stage/service
+func (k Key) String() string {
+	return k.KeptnContext + "/" + k.Stage + "/" + k.Service
+}
+
+// Store persists the results of locust runs on disk, grouped by keptnContext, stage and service
+type Store struct {
+	Dir string
+	// MaxAge is the time after which the results of a keptnContext are removed by Clean (0 retains them)
+	MaxAge time.Duration
+}
+
+// NewStore creates a new Store writing into the given directory
+func NewStore(dir string) *Store {
+	return &Store{
+		Dir: dir,
+	}
+}
+
+// SaveStatistics stores the parsed statistics of the run with the given key
+func (s *Store) SaveStatistics(key Key, statistics *stats.Statistics) error {
+	content, err := json.Marshal(statistics)
+	if err != nil {
+		return err
+	}
+	return s.SaveArtifact(key, StatisticsFilename, content)
+}
+
+// LoadStatistics returns the statistics that have been stored for the run with the given key
+func (s *Store) LoadStatistics(key Key) (*stats.Statistics, error) {
+	content, err := s.LoadArtifact(key, StatisticsFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	statistics := &stats.Statistics{}
+	if err := json.Unmarshal(content, statistics); err != nil {
+		return nil, fmt.Errorf("could not parse statistics of %s: %s", key, err.Error())
+	}
+	return statistics, nil
+}
+
+// SaveArtifact stores a file belonging to the run with the given key
+func (s *Store) SaveArtifact(key Key, name string, content []byte) error {
+	dir, err := s.runDir(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, filepath.Base(name)), content, 0644)
+}
+
+// LoadArtifact returns a file belonging to the run with the given key
+func (s *Store) LoadArtifact(key Key, name string) ([]byte, error) {
+	dir, err := s.runDir(key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(filepath.Join(dir, filepath.Base(name)))
+}
+
+// Clean removes the results of the keptnContexts that have not been written to for longer than MaxAge and returns
+// the removed keptnContexts
+func (s *Store) Clean() ([]string, error) {
+	removed := []string{}
+	if s.MaxAge <= 0 {
+		return removed, nil
+	}
+
+	entries, err := ioutil.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return removed, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.Dir, entry.Name())
+		if time.Since(lastModified(dir, entry.ModTime())) <= s.MaxAge {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return removed, fmt.Errorf("could not remove results of %s: %w", entry.Name(), err)
+		}
+		removed = append(removed, entry.Name())
+	}
+	return removed, nil
+}
+
+// RunJanitor cleans the results right away and then in the given interval, until the context is done
+func (s *Store) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := s.Clean()
+		if err != nil {
+			log.Printf("Could not clean results: %s", err.Error())
+		}
+		for _, keptnContext := range removed {
+			log.Printf("Removed results of %s", keptnContext)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lastModified returns the latest modification time of the directory and everything in it, overwriting an artifact
+// does not change the modification time of its directory
+func lastModified(dir string, modTime time.Time) time.Time {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+		return nil
+	})
+	return modTime
+}
+
+// runDir returns the directory of the key, <Dir>/<keptnContext>/<stage>/<service>
+func (s *Store) runDir(key Key) (string, error) {
+	for _, part := range []string{key.KeptnContext, key.Stage, key.Service} {
+		if part == "" || part == "." || part == ".." || strings.ContainsAny(part, `/\`) {
+			return "", fmt.Errorf("invalid key %q", key.String())
+		}
+	}
+	return filepath.Join(s.Dir, key.KeptnContext, key.Stage, key.Service), nil
+}
@@ -0,0 +1,93 @@
+package results
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func createStore(t *testing.T) *Store {
+	dir, err := ioutil.TempDir("", "results")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return NewStore(dir)
+}
+
+// key returns the key of the carts service in the dev stage for the keptnContext
+func key(keptnContext string) Key {
+	return Key{KeptnContext: keptnContext, Stage: "dev", Service: "carts"}
+}
+
+func TestSaveAndLoadStatistics(t *testing.T) {
+	store := createStore(t)
+	statistics := &stats.Statistics{
+		Endpoints:  []stats.RequestStats{{Type: "GET", Name: "/carts", RequestCount: 10, P95: 42}},
+		Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 10, P95: 42},
+	}
+
+	err := store.SaveStatistics(key("a1b2c3"), statistics)
+	assert.NoError(t, err)
+
+	loaded, err := store.LoadStatistics(key("a1b2c3"))
+	assert.NoError(t, err)
+	assert.Equal(t, statistics, loaded)
+
+	// the keptnContext spans all stages of the sequence, the statistics only belong to the stage of the test
+	_, err = store.LoadStatistics(Key{KeptnContext: "a1b2c3", Stage: "staging", Service: "carts"})
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLoadStatistics_NotFound(t *testing.T) {
+	store := createStore(t)
+
+	_, err := store.LoadStatistics(key("unknown"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSaveArtifact_InvalidKey(t *testing.T) {
+	store := createStore(t)
+
+	assert.Error(t, store.SaveArtifact(key("../escape"), "file.txt", []byte("content")))
+	assert.Error(t, store.SaveArtifact(key(""), "file.txt", []byte("content")))
+	assert.Error(t, store.SaveArtifact(Key{KeptnContext: "a1b2c3", Stage: "..", Service: "carts"}, "file.txt", []byte("content")))
+}
+
+func TestClean(t *testing.T) {
+	store := createStore(t)
+	store.MaxAge = time.Hour
+
+	assert.NoError(t, store.SaveArtifact(key("old"), "file.txt", []byte("content")))
+	assert.NoError(t, store.SaveArtifact(key("recent"), "file.txt", []byte("content")))
+	past := time.Now().Add(-2 * time.Hour)
+	for _, path := range []string{"old/dev/carts/file.txt", "old/dev/carts", "old/dev", "old"} {
+		assert.NoError(t, os.Chtimes(filepath.Join(store.Dir, path), past, past))
+	}
+
+	removed, err := store.Clean()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"old"}, removed)
+
+	_, err = store.LoadArtifact(key("old"), "file.txt")
+	assert.True(t, os.IsNotExist(err))
+	_, err = store.LoadArtifact(key("recent"), "file.txt")
+	assert.NoError(t, err)
+}
+
+func TestClean_RetainAll(t *testing.T) {
+	store := createStore(t)
+
+	assert.NoError(t, store.SaveArtifact(key("old"), "file.txt", []byte("content")))
+	past := time.Now().Add(-24 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(store.Dir, "old"), past, past))
+
+	removed, err := store.Clean()
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+}
@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+)
+
+// Fake is a Runner for tests, every run finishes with the configured output and error
+type Fake struct {
+	Output string
+	Err    error
+	// Files are written into the WorkDir of every run (relative to it), e.g. the CSV statistics
+	Files map[string]string
+	// Block keeps the runs running until they are stopped
+	Block bool
+
+	mutex sync.Mutex
+	specs []Spec
+}
+
+// Start records the spec and finishes the run in the background
+func (f *Fake) Start(spec Spec) (Execution, error) {
+	f.mutex.Lock()
+	f.specs = append(f.specs, spec)
+	f.mutex.Unlock()
+
+	return startAsync(func(ctx context.Context) (string, error) {
+		if f.Block {
+			<-ctx.Done()
+		}
+		for name, content := range f.Files {
+			if err := ioutil.WriteFile(filepath.Join(spec.WorkDir, name), []byte(content), 0644); err != nil {
+				return "", err
+			}
+		}
+		if spec.Output != nil {
+			io.WriteString(spec.Output, f.Output)
+		}
+		return f.Output, f.Err
+	}), nil
+}
+
+// Specs returns the specs of every run started so far
+func (f *Fake) Specs() []Spec {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Spec{}, f.specs...)
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/keptn-sandbox/locust-service/pkg/kubejob"
+	"github.com/keptn-sandbox/locust-service/pkg/logstream"
+)
+
+// Kubernetes runs locust as Kubernetes job, the Workers and the Command of the spec are ignored.
+// The output is streamed from the logs of the job while it is running.
+type Kubernetes struct {
+	Jobs *kubejob.Runner
+	// OutputLimit is the number of bytes at the end of the output that are kept for the result and the errors
+	// (default: logstream.DefaultLimit)
+	OutputLimit int
+}
+
+// NewKubernetes creates a runner executing locust with the jobs of the given runner
+func NewKubernetes(jobs *kubejob.Runner) *Kubernetes {
+	return &Kubernetes{
+		Jobs: jobs,
+	}
+}
+
+// Start creates the job in the background, stopping the run deletes the job
+func (k *Kubernetes) Start(spec Spec) (Execution, error) {
+	log.Println("Running locust as Kubernetes job")
+	return startAsync(func(ctx context.Context) (string, error) {
+		// only the end of the logs is kept in memory
+		tail := logstream.NewRing(k.OutputLimit)
+		writer := io.Writer(tail)
+		if spec.Output != nil {
+			writer = io.MultiWriter(tail, spec.Output)
+		}
+
+		err := k.Jobs.Run(ctx, kubejob.Spec{
+			Name:        kubejob.JobName(spec.ID),
+			Namespace:   spec.Namespace,
+			WorkDir:     spec.WorkDir,
+			Args:        spec.Args,
+			SecretName:  spec.SecretName,
+			ResultFiles: spec.ResultFiles,
+			Labels:      spec.Labels,
+			Output:      writer,
+		})
+
+		var jobErr *kubejob.JobFailedError
+		if errors.As(err, &jobErr) {
+			jobErr.Output = tail.String()
+		}
+		if err != nil {
+			return "", err
+		}
+		return tail.String(), nil
+	}), nil
+}
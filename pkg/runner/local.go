@@ -0,0 +1,111 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/distributed"
+	"github.com/keptn-sandbox/locust-service/pkg/logstream"
+	"github.com/keptn-sandbox/locust-service/pkg/process"
+)
+
+// DefaultCommand is the locust executable the local runner starts
+const DefaultCommand = "locust"
+
+// Local runs locust as child process of the service, distributed runs start a local master and local workers
+type Local struct {
+	// Command is the locust executable
+	Command string
+	// GracePeriod defines how long locust may take to shut down after SIGTERM before it is killed
+	GracePeriod time.Duration
+	// OutputLimit is the number of bytes at the end of the output that are kept for the result and the errors
+	// (default: logstream.DefaultLimit), the complete output is only passed to the Output of the spec
+	OutputLimit int
+}
+
+// NewLocal creates a runner starting the locust executable from the PATH
+func NewLocal(gracePeriod time.Duration) *Local {
+	return &Local{
+		Command:     DefaultCommand,
+		GracePeriod: gracePeriod,
+	}
+}
+
+// Start starts locust in its own process group, so stopping it also stops every process it has spawned
+func (l *Local) Start(spec Spec) (Execution, error) {
+	command, args, workerArgs := l.Command, spec.Args, spec.WorkerArgs
+	if len(spec.Command) > 0 {
+		command = spec.Command[0]
+		args = append(append([]string{}, spec.Command[1:]...), spec.Args...)
+		workerArgs = append(append([]string{}, spec.Command[1:]...), spec.WorkerArgs...)
+	}
+
+	if spec.Workers > 0 {
+		log.Printf("Running locust distributed with %d workers", spec.Workers)
+		return startAsync(func(ctx context.Context) (string, error) {
+			return distributed.Run(ctx, command, args, workerArgs, spec.Workers, spec.Env, distributed.Options{
+				GracePeriod: l.GracePeriod,
+				Output:      spec.Output,
+				OutputLimit: l.OutputLimit,
+			})
+		}), nil
+	}
+
+	execution := &localExecution{
+		cmd:         exec.Command(command, args...),
+		gracePeriod: l.GracePeriod,
+		output:      logstream.NewRing(l.OutputLimit),
+		done:        make(chan struct{}),
+	}
+	execution.cmd.Env = append(execution.cmd.Env, spec.Env...)
+	execution.cmd.Stdout = execution.output
+	if spec.Output != nil {
+		execution.cmd.Stdout = io.MultiWriter(execution.output, spec.Output)
+	}
+	execution.cmd.Stderr = execution.cmd.Stdout
+	process.UseProcessGroup(execution.cmd)
+
+	if err := execution.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error executing command %s %s: %w", command, strings.Join(args, " "), err)
+	}
+	go func() {
+		defer close(execution.done)
+		execution.err = execution.cmd.Wait()
+	}()
+	return execution, nil
+}
+
+// localExecution is a single locust process
+type localExecution struct {
+	cmd         *exec.Cmd
+	gracePeriod time.Duration
+	output      *logstream.Ring
+	done        chan struct{}
+	err         error
+	stopOnce    sync.Once
+}
+
+func (e *localExecution) Wait() (string, error) {
+	<-e.done
+	if e.err != nil {
+		return "", fmt.Errorf("Error executing command %s: %w\n%s", strings.Join(e.cmd.Args, " "), e.err, e.output.String())
+	}
+	return e.output.String(), nil
+}
+
+func (e *localExecution) Stop() {
+	e.stopOnce.Do(func() {
+		exited := make(chan error, 1)
+		go func() {
+			<-e.done
+			exited <- e.err
+		}()
+		process.Terminate(e.cmd, e.gracePeriod, exited)
+	})
+}
@@ -0,0 +1,109 @@
+package runner
+
+import (
+	"context"
+	"io"
+)
+
+// Spec describes a single locust run
+type Spec struct {
+	// ID identifies the run, e.g. the Kubernetes job is named after it
+	ID string
+	// Command replaces the locust command of the runner, e.g. to run locust from a virtualenv (optional)
+	Command []string
+	// Args are the arguments of locust, of the master if the run is distributed
+	Args []string
+	// WorkerArgs are the arguments of the locust workers
+	WorkerArgs []string
+	// Workers is the number of locust workers, 0 runs locust in a single process
+	Workers int
+	// Env holds additional environment variables of locust (KEY=value)
+	Env []string
+	// WorkDir contains the scripts of the run, locust writes its result files into it
+	WorkDir string
+	// ResultFiles are the files locust writes into the WorkDir (relative to it)
+	ResultFiles []string
+	// Namespace the run is executed in, if the runner creates Kubernetes resources
+	Namespace string
+	// SecretName is the secret holding the environment variables, if the runner creates Kubernetes resources
+	SecretName string
+	// Labels are added to the Kubernetes resources created for the run
+	Labels map[string]string
+	// Output receives the output of locust while it is running (optional)
+	Output io.Writer
+}
+
+// Runner starts locust runs, e.g. as local processes or as Kubernetes jobs
+type Runner interface {
+	// Start starts locust for the spec and returns without waiting for it to finish
+	Start(spec Spec) (Execution, error)
+}
+
+// Execution is a started locust run
+type Execution interface {
+	// Wait blocks until locust has finished and returns its output
+	Wait() (string, error)
+	// Stop terminates locust gracefully (so it writes the statistics collected so far) and blocks until it has finished
+	Stop()
+}
+
+// Run starts the spec with the runner and waits until it has finished. If the context is done before, the run is
+// stopped and the error of the context is returned.
+func Run(ctx context.Context, runner Runner, spec Spec) (string, error) {
+	execution, err := runner.Start(spec)
+	if err != nil {
+		return "", err
+	}
+
+	finished := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			execution.Stop()
+		case <-finished:
+		}
+	}()
+
+	output, err := execution.Wait()
+	close(finished)
+	<-stopped
+
+	if ctx.Err() != nil {
+		return output, ctx.Err()
+	}
+	return output, err
+}
+
+// asyncExecution runs a blocking function in the background, Stop cancels the context of the function
+type asyncExecution struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	output string
+	err    error
+}
+
+func startAsync(run func(ctx context.Context) (string, error)) *asyncExecution {
+	ctx, cancel := context.WithCancel(context.Background())
+	execution := &asyncExecution{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(execution.done)
+		defer cancel()
+		execution.output, execution.err = run(ctx)
+	}()
+	return execution
+}
+
+func (e *asyncExecution) Wait() (string, error) {
+	<-e.done
+	return e.output, e.err
+}
+
+func (e *asyncExecution) Stop() {
+	e.cancel()
+	<-e.done
+}
@@ -0,0 +1,106 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocal(t *testing.T) {
+	var output bytes.Buffer
+	local := &Local{Command: "sh", GracePeriod: time.Second}
+
+	execution, err := local.Start(Spec{Args: []string{"-c", "echo $MY_VAR"}, Env: []string{"MY_VAR=hello"}, Output: &output})
+	assert.NoError(t, err)
+	result, err := execution.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", result)
+	assert.Equal(t, "hello\n", output.String())
+}
+
+func TestLocal_Command(t *testing.T) {
+	local := &Local{Command: "does-not-exist", GracePeriod: time.Second}
+
+	execution, err := local.Start(Spec{Command: []string{"sh", "-c"}, Args: []string{"echo from command"}})
+	assert.NoError(t, err)
+	result, err := execution.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "from command\n", result)
+}
+
+func TestLocal_OutputLimit(t *testing.T) {
+	var output bytes.Buffer
+	local := &Local{Command: "sh", GracePeriod: time.Second, OutputLimit: 16}
+
+	execution, err := local.Start(Spec{Args: []string{"-c", "for i in 1 2 3 4 5 6 7 8 9; do echo line $i; done"}, Output: &output})
+	assert.NoError(t, err)
+	result, err := execution.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "[... 47 bytes omitted]\n7\nline 8\nline 9\n", result)
+	assert.Contains(t, output.String(), "line 1\n")
+}
+
+func TestLocal_Failed(t *testing.T) {
+	local := &Local{Command: "sh", GracePeriod: time.Second}
+
+	execution, err := local.Start(Spec{Args: []string{"-c", "echo broken; exit 3"}})
+	assert.NoError(t, err)
+	_, err = execution.Wait()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+
+	_, err = (&Local{Command: "does-not-exist"}).Start(Spec{})
+	assert.Error(t, err)
+}
+
+func TestLocal_Stop(t *testing.T) {
+	local := &Local{Command: "sh", GracePeriod: 5 * time.Second}
+
+	// the shell shuts down gracefully on SIGTERM
+	execution, err := local.Start(Spec{Args: []string{"-c", "trap 'echo stopped; exit 0' TERM; sleep 10 & wait"}})
+	assert.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	start := time.Now()
+	execution.Stop()
+	assert.True(t, time.Since(start) < 5*time.Second)
+	output, err := execution.Wait()
+	assert.NoError(t, err)
+	assert.Equal(t, "stopped\n", output)
+}
+
+func TestRun_Canceled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	fake := &Fake{Block: true, Output: "partial"}
+	output, err := Run(ctx, fake, Spec{ID: "1"})
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, "partial", output)
+}
+
+func TestFake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runner")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var output bytes.Buffer
+	fake := &Fake{Output: "done", Err: errors.New("failed"), Files: map[string]string{"locust_stats.csv": "stats"}}
+	result, err := Run(context.Background(), fake, Spec{ID: "1", WorkDir: dir, Output: &output})
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, "done", result)
+	assert.Equal(t, "done", output.String())
+
+	content, err := ioutil.ReadFile(filepath.Join(dir, "locust_stats.csv"))
+	assert.NoError(t, err)
+	assert.Equal(t, "stats", string(content))
+	assert.Len(t, fake.Specs(), 1)
+	assert.Equal(t, "1", fake.Specs()[0].ID)
+}
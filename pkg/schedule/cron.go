@@ -0,0 +1,181 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression with the fields minute, hour, day of month, month and day of week, or one of the
+// descriptors @yearly, @monthly, @weekly, @daily, @hourly and @every <duration>
+type Cron struct {
+	minute     uint64
+	hour       uint64
+	dayOfMonth uint64
+	month      uint64
+	dayOfWeek  uint64
+	// the days match if either field matches, unless one of them is a wildcard
+	dayOfMonthWildcard bool
+	dayOfWeekWildcard  bool
+	// every is set for @every, the expression then fires in a fixed interval
+	every time.Duration
+}
+
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// field defines the range of a cron field
+type field struct {
+	name string
+	min  int
+	max  int
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	// 7 is Sunday as well
+	{name: "day of week", min: 0, max: 7},
+}
+
+// ParseCron parses a cron expression, e.g. "*/15 * * * *", "0 6-18 * * 1-5" or "@every 10m"
+func ParseCron(expression string) (*Cron, error) {
+	expression = strings.TrimSpace(expression)
+
+	if strings.HasPrefix(expression, "@every ") {
+		every, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(expression, "@every ")))
+		if err != nil || every < time.Minute {
+			return nil, fmt.Errorf("invalid interval in %s, must be at least 1m", expression)
+		}
+		return &Cron{every: every}, nil
+	}
+	if descriptor, ok := descriptors[expression]; ok {
+		expression = descriptor
+	}
+
+	parts := strings.Fields(expression)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("invalid cron expression %s, expected %d fields", expression, len(fields))
+	}
+
+	values := make([]uint64, len(fields))
+	for i, part := range parts {
+		value, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+
+	// Sunday may be given as 0 or 7
+	if values[4]&(1<<7) != 0 {
+		values[4] |= 1
+	}
+
+	return &Cron{
+		minute:             values[0],
+		hour:               values[1],
+		dayOfMonth:         values[2],
+		month:              values[3],
+		dayOfWeek:          values[4],
+		dayOfMonthWildcard: strings.HasPrefix(parts[2], "*"),
+		dayOfWeekWildcard:  strings.HasPrefix(parts[4], "*"),
+	}, nil
+}
+
+// parseField parses a comma separated list of values, ranges (a-b) and steps (*/n or a-b/n) into a bit set
+func parseField(value string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(value, ",") {
+		rangeExpression, step := item, 1
+		if index := strings.Index(item, "/"); index >= 0 {
+			parsed, err := strconv.Atoi(item[index+1:])
+			if err != nil || parsed < 1 {
+				return 0, fmt.Errorf("invalid step in %s field: %s", f.name, item)
+			}
+			rangeExpression, step = item[:index], parsed
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case rangeExpression == "*":
+		case strings.Contains(rangeExpression, "-"):
+			bounds := strings.SplitN(rangeExpression, "-", 2)
+			var err error
+			if start, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid range in %s field: %s", f.name, item)
+			}
+			if end, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, fmt.Errorf("invalid range in %s field: %s", f.name, item)
+			}
+		default:
+			parsed, err := strconv.Atoi(rangeExpression)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %s field: %s", f.name, item)
+			}
+			start, end = parsed, parsed
+			if step > 1 {
+				// a/n means every n-th value starting at a
+				end = f.max
+			}
+		}
+
+		if start < f.min || end > f.max || start > end {
+			return 0, fmt.Errorf("%s field out of range %d-%d: %s", f.name, f.min, f.max, item)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+// Next returns the first time after t the expression fires
+func (c *Cron) Next(t time.Time) time.Time {
+	if c.every > 0 {
+		return t.Truncate(time.Second).Add(c.every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// every combination of the fields repeats within a few years, so there is no match if none is found by then
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *Cron) matchesDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.dayOfMonthWildcard || c.dayOfWeekWildcard {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
@@ -0,0 +1,230 @@
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Filename is the resource of a service defining its schedules
+const Filename = "locust/schedule.yaml"
+
+// Config is the content of the schedule.yaml
+type Config struct {
+	Schedules []*Schedule `json:"schedules" yaml:"schedules"`
+}
+
+// Schedule runs a workload against a host independent of Keptn
+type Schedule struct {
+	// Name identifies the schedule within the service
+	Name string `json:"name" yaml:"name"`
+	// Cron defines when the workload runs, e.g. "*/15 * * * *" or "@every 30m"
+	Cron string `json:"cron" yaml:"cron"`
+	// Workload is the name of the workload in the locust.conf.yaml of the service
+	Workload string `json:"workload" yaml:"workload"`
+	// Host is the URL locust runs against
+	Host string `json:"host" yaml:"host"`
+}
+
+// Parse parses and validates the content of a schedule.yaml
+func Parse(content []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.Unmarshal(content, config); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, schedule := range config.Schedules {
+		if schedule.Name == "" {
+			return nil, errors.New("every schedule needs a name")
+		}
+		if names[schedule.Name] {
+			return nil, fmt.Errorf("duplicate schedule %s", schedule.Name)
+		}
+		names[schedule.Name] = true
+
+		if _, err := ParseCron(schedule.Cron); err != nil {
+			return nil, fmt.Errorf("invalid cron of schedule %s: %s", schedule.Name, err.Error())
+		}
+		if schedule.Workload == "" {
+			return nil, fmt.Errorf("schedule %s has no workload", schedule.Name)
+		}
+		host, err := url.Parse(schedule.Host)
+		if err != nil || (host.Scheme != "http" && host.Scheme != "https") || host.Host == "" {
+			return nil, fmt.Errorf("invalid host of schedule %s: %s", schedule.Name, schedule.Host)
+		}
+	}
+	return config, nil
+}
+
+// Entry is a schedule of a service
+type Entry struct {
+	Project  string
+	Stage    string
+	Service  string
+	Schedule *Schedule
+
+	cron *Cron
+}
+
+// ID identifies the entry among the schedules of all services
+func (e *Entry) ID() string {
+	return fmt.Sprintf("%s/%s/%s/%s", e.Project, e.Stage, e.Service, e.Schedule.Name)
+}
+
+// scheduled is an entry with the time it fires next
+type scheduled struct {
+	entry   *Entry
+	next    time.Time
+	running bool
+}
+
+// Scheduler runs the entries of the services on their schedule. If the previous run of an entry has not finished
+// yet, the entry is skipped. It is safe for concurrent use.
+type Scheduler struct {
+	// Run runs the test of the entry and blocks until it has finished
+	Run func(entry *Entry)
+
+	mutex   sync.Mutex
+	entries map[string]*scheduled
+	changed chan struct{}
+	now     func() time.Time
+}
+
+// New creates a Scheduler without entries
+func New(run func(entry *Entry)) *Scheduler {
+	return &Scheduler{
+		Run:     run,
+		entries: map[string]*scheduled{},
+		changed: make(chan struct{}, 1),
+		now:     time.Now,
+	}
+}
+
+// Set replaces the schedules of the service. Entries whose cron expression has not changed keep their next time.
+func (s *Scheduler) Set(project string, stage string, service string, schedules []*Schedule) error {
+	entries := map[string]*Entry{}
+	for _, schedule := range schedules {
+		cron, err := ParseCron(schedule.Cron)
+		if err != nil {
+			return fmt.Errorf("invalid cron of schedule %s: %s", schedule.Name, err.Error())
+		}
+		entry := &Entry{Project: project, Stage: stage, Service: service, Schedule: schedule, cron: cron}
+		entries[entry.ID()] = entry
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	prefix := fmt.Sprintf("%s/%s/%s/", project, stage, service)
+	for id := range s.entries {
+		if _, ok := entries[id]; strings.HasPrefix(id, prefix) && !ok {
+			delete(s.entries, id)
+		}
+	}
+	now := s.now()
+	for id, entry := range entries {
+		existing, ok := s.entries[id]
+		if ok && existing.entry.Schedule.Cron == entry.Schedule.Cron {
+			existing.entry = entry
+			continue
+		}
+		next := entry.cron.Next(now)
+		if ok {
+			existing.entry, existing.next = entry, next
+			continue
+		}
+		s.entries[id] = &scheduled{entry: entry, next: next}
+	}
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Entries returns the IDs of all entries with the time they run next
+func (s *Scheduler) Entries() map[string]time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entries := map[string]time.Time{}
+	for id, scheduled := range s.entries {
+		entries[id] = scheduled.next
+	}
+	return entries
+}
+
+// Start runs the entries on their schedule until the context is done
+func (s *Scheduler) Start(ctx context.Context) {
+	for {
+		wait := s.tick()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.changed:
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// tick starts the entries that are due and returns the time until the next entry is due
+func (s *Scheduler) tick() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	wait := time.Hour
+
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		entry := s.entries[id]
+		if entry.next.IsZero() {
+			// the cron expression never fires
+			continue
+		}
+		if !now.Before(entry.next) {
+			entry.next = entry.entry.cron.Next(now)
+			if entry.running {
+				log.Printf("Skipping schedule %s, its previous run has not finished yet", id)
+			} else {
+				entry.running = true
+				go s.run(entry, entry.entry)
+			}
+		}
+		if !entry.next.IsZero() && entry.next.Sub(now) < wait {
+			wait = entry.next.Sub(now)
+		}
+	}
+	return wait
+}
+
+// run runs the entry and allows it to run again once it has finished
+func (s *Scheduler) run(state *scheduled, entry *Entry) {
+	defer func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		state.running = false
+	}()
+
+	log.Printf("Running schedule %s", entry.ID())
+	s.Run(entry)
+}
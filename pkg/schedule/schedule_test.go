@@ -0,0 +1,110 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	start := time.Date(2021, time.September, 15, 10, 7, 30, 0, time.UTC) // a Wednesday
+
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{expression: "* * * * *", expected: time.Date(2021, time.September, 15, 10, 8, 0, 0, time.UTC)},
+		{expression: "*/15 * * * *", expected: time.Date(2021, time.September, 15, 10, 15, 0, 0, time.UTC)},
+		{expression: "5,40 * * * *", expected: time.Date(2021, time.September, 15, 10, 40, 0, 0, time.UTC)},
+		{expression: "0 6-18/4 * * *", expected: time.Date(2021, time.September, 15, 14, 0, 0, 0, time.UTC)},
+		{expression: "30 2 * * 1-5", expected: time.Date(2021, time.September, 16, 2, 30, 0, 0, time.UTC)},
+		{expression: "0 0 * * 7", expected: time.Date(2021, time.September, 19, 0, 0, 0, 0, time.UTC)},
+		// day of month and day of week match if either of them matches
+		{expression: "0 0 1 * 5", expected: time.Date(2021, time.September, 17, 0, 0, 0, 0, time.UTC)},
+		{expression: "@monthly", expected: time.Date(2021, time.October, 1, 0, 0, 0, 0, time.UTC)},
+		{expression: "@hourly", expected: time.Date(2021, time.September, 15, 11, 0, 0, 0, time.UTC)},
+		{expression: "@every 10m", expected: time.Date(2021, time.September, 15, 10, 17, 30, 0, time.UTC)},
+		{expression: "0 0 29 2 *", expected: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		cron, err := ParseCron(test.expression)
+		assert.NoError(t, err, test.expression)
+		assert.Equal(t, test.expected, cron.Next(start), test.expression)
+	}
+
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 10s", "@sometimes"} {
+		_, err := ParseCron(expression)
+		assert.Error(t, err, expression)
+	}
+
+	// the expression never fires
+	cron, err := ParseCron("0 0 31 2 *")
+	assert.NoError(t, err)
+	assert.True(t, cron.Next(start).IsZero())
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse([]byte(`---
+schedules:
+  - name: warm-caches
+    cron: "*/15 * * * *"
+    workload: background
+    host: http://carts.sockshop-production:80
+`))
+	assert.NoError(t, err)
+	assert.Len(t, config.Schedules, 1)
+	assert.Equal(t, "background", config.Schedules[0].Workload)
+
+	invalid := []string{
+		"schedules:\n  - cron: '* * * * *'\n    workload: w\n    host: http://carts\n",
+		"schedules:\n  - name: a\n    cron: 'soon'\n    workload: w\n    host: http://carts\n",
+		"schedules:\n  - name: a\n    cron: '* * * * *'\n    host: http://carts\n",
+		"schedules:\n  - name: a\n    cron: '* * * * *'\n    workload: w\n    host: carts\n",
+		"schedules:\n  - name: a\n    cron: '* * * * *'\n    workload: w\n    host: http://carts\n  - name: a\n    cron: '* * * * *'\n    workload: w\n    host: http://carts\n",
+	}
+	for _, content := range invalid {
+		_, err := Parse([]byte(content))
+		assert.Error(t, err, content)
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	runs := make(chan string, 10)
+	finish := make(chan struct{})
+	scheduler := New(func(entry *Entry) {
+		runs <- entry.ID()
+		<-finish
+	})
+	now := time.Date(2021, time.September, 15, 10, 7, 30, 0, time.UTC)
+	scheduler.now = func() time.Time { return now }
+
+	assert.NoError(t, scheduler.Set("sockshop", "production", "carts", []*Schedule{
+		{Name: "warm-caches", Cron: "*/15 * * * *", Workload: "background", Host: "http://carts"},
+	}))
+	assert.Equal(t, map[string]time.Time{
+		"sockshop/production/carts/warm-caches": time.Date(2021, time.September, 15, 10, 15, 0, 0, time.UTC),
+	}, scheduler.Entries())
+
+	assert.Equal(t, 7*time.Minute+30*time.Second, scheduler.tick())
+	assert.Len(t, runs, 0)
+
+	now = time.Date(2021, time.September, 15, 10, 15, 0, 0, time.UTC)
+	assert.Equal(t, 15*time.Minute, scheduler.tick())
+	assert.Equal(t, "sockshop/production/carts/warm-caches", <-runs)
+
+	// the previous run has not finished yet, so the entry is skipped
+	now = time.Date(2021, time.September, 15, 10, 30, 0, 0, time.UTC)
+	scheduler.tick()
+	close(finish)
+	select {
+	case id := <-runs:
+		t.Fatalf("%s has run although its previous run has not finished", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// removed schedules do not run anymore
+	assert.NoError(t, scheduler.Set("sockshop", "production", "carts", nil))
+	assert.Empty(t, scheduler.Entries())
+}
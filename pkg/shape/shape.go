@@ -0,0 +1,254 @@
+package shape
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// Filename is the name of the generated locustfile containing the shape
+const Filename = "keptn_shape_locustfile.py"
+
+// Profile is a built-in load shape
+type Profile string
+
+const (
+	// ProfileRamp increases the users linearly to the peak over the whole duration
+	ProfileRamp Profile = "ramp"
+	// ProfileStep increases the users to the peak in equal steps
+	ProfileStep Profile = "step"
+	// ProfileSpike runs a low base load with a short spike to the peak in the middle
+	ProfileSpike Profile = "spike"
+	// ProfileSoak ramps up to the peak within the first tenth of the duration and holds it
+	ProfileSoak Profile = "soak"
+)
+
+// DefaultSteps is the number of steps of the step profile
+const DefaultSteps = 5
+
+// Stage is a period of the test with a constant target number of users
+type Stage struct {
+	// Duration of the stage, e.g. 5m
+	Duration string `json:"duration" yaml:"duration"`
+	// Users is the target number of users of the stage
+	Users int `json:"users" yaml:"users"`
+	// SpawnRate is the number of users started (or stopped) per second to reach the target
+	SpawnRate float64 `json:"spawn_rate" yaml:"spawn_rate"`
+}
+
+// Config describes the shape of a load test, either as list of stages or as built-in profile
+type Config struct {
+	Stages []Stage `json:"stages" yaml:"stages"`
+
+	Profile Profile `json:"profile" yaml:"profile"`
+	// Users is the peak number of users of the profile
+	Users int `json:"users" yaml:"users"`
+	// Duration is the total duration of the profile, e.g. 30m
+	Duration string `json:"duration" yaml:"duration"`
+	// SpawnRate is the number of users started per second for step and spike (default: the users of a step or spike)
+	SpawnRate float64 `json:"spawn_rate" yaml:"spawn_rate"`
+	// Steps is the number of steps of the step profile (default: 5)
+	Steps int `json:"steps" yaml:"steps"`
+}
+
+// Validate checks the shape configuration
+func (c *Config) Validate() error {
+	if len(c.Stages) > 0 && c.Profile != "" {
+		return errors.New("stages and profile cannot be combined")
+	}
+
+	if len(c.Stages) > 0 {
+		for i, stage := range c.Stages {
+			if _, err := parseDuration(stage.Duration); err != nil {
+				return fmt.Errorf("invalid duration of stage %d: %s", i+1, stage.Duration)
+			}
+			if stage.Users < 0 {
+				return fmt.Errorf("invalid users of stage %d: %d", i+1, stage.Users)
+			}
+			if stage.SpawnRate <= 0 {
+				return fmt.Errorf("invalid spawn_rate of stage %d: %g", i+1, stage.SpawnRate)
+			}
+		}
+		return nil
+	}
+
+	switch c.Profile {
+	case ProfileRamp, ProfileStep, ProfileSpike, ProfileSoak:
+	case "":
+		return errors.New("either stages or a profile is required")
+	default:
+		return fmt.Errorf("unknown profile %s, must be one of %s, %s, %s or %s", c.Profile, ProfileRamp, ProfileStep, ProfileSpike, ProfileSoak)
+	}
+	if c.Users < 1 {
+		return fmt.Errorf("invalid users: %d", c.Users)
+	}
+	if _, err := parseDuration(c.Duration); err != nil {
+		return fmt.Errorf("invalid duration: %s", c.Duration)
+	}
+	if c.SpawnRate < 0 {
+		return fmt.Errorf("invalid spawn_rate: %g", c.SpawnRate)
+	}
+	if c.Steps < 0 {
+		return fmt.Errorf("invalid steps: %d", c.Steps)
+	}
+	return nil
+}
+
+// ResolvedStage is a stage with the offset of its end from the start of the test
+type ResolvedStage struct {
+	End       time.Duration
+	Users     int
+	SpawnRate float64
+}
+
+// Resolve returns the stages of the shape, profiles are converted into stages. The config has to be valid.
+func (c *Config) Resolve() []ResolvedStage {
+	stages := []ResolvedStage{}
+	var end time.Duration
+	add := func(duration time.Duration, users int, spawnRate float64) {
+		end += duration
+		stages = append(stages, ResolvedStage{End: end, Users: users, SpawnRate: spawnRate})
+	}
+
+	if len(c.Stages) > 0 {
+		for _, stage := range c.Stages {
+			duration, _ := parseDuration(stage.Duration)
+			add(duration, stage.Users, stage.SpawnRate)
+		}
+		return stages
+	}
+
+	duration, _ := parseDuration(c.Duration)
+	seconds := duration.Seconds()
+
+	switch c.Profile {
+	case ProfileRamp:
+		add(duration, c.Users, float64(c.Users)/seconds)
+
+	case ProfileStep:
+		steps := c.Steps
+		if steps == 0 {
+			steps = DefaultSteps
+		}
+		for i := 1; i <= steps; i++ {
+			users := int(math.Round(float64(c.Users) * float64(i) / float64(steps)))
+			add(duration/time.Duration(steps), users, c.spawnRate(float64(c.Users)/float64(steps)))
+		}
+
+	case ProfileSpike:
+		base := int(math.Max(1, math.Round(float64(c.Users)/10)))
+		add(duration*4/10, base, c.spawnRate(float64(base)))
+		add(duration*2/10, c.Users, c.spawnRate(float64(c.Users-base)))
+		add(duration-duration*6/10, base, c.spawnRate(float64(c.Users-base)))
+
+	case ProfileSoak:
+		rampUp := duration / 10
+		add(rampUp, c.Users, float64(c.Users)/rampUp.Seconds())
+		add(duration-rampUp, c.Users, float64(c.Users)/rampUp.Seconds())
+	}
+	return stages
+}
+
+// PeakUsers returns the highest number of users of the shape
+func (c *Config) PeakUsers() int {
+	peak := 0
+	for _, stage := range c.Resolve() {
+		if stage.Users > peak {
+			peak = stage.Users
+		}
+	}
+	return peak
+}
+
+// TotalDuration returns the duration of the shape, after which the test stops
+func (c *Config) TotalDuration() time.Duration {
+	stages := c.Resolve()
+	if len(stages) == 0 {
+		return 0
+	}
+	return stages[len(stages)-1].End
+}
+
+// spawnRate returns the configured spawn rate or the given default, which is at least 1 user per second
+func (c *Config) spawnRate(defaultRate float64) float64 {
+	if c.SpawnRate > 0 {
+		return c.SpawnRate
+	}
+	return math.Max(1, defaultRate)
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, errors.New("duration must be positive")
+	}
+	return duration, nil
+}
+
+var locustfileTemplate = template.Must(template.New("locustfile").Parse(`# Generated by the locust-service from the shape in locust.conf.yaml, do not edit
+import importlib.util
+import inspect
+import os
+import sys
+
+from locust import LoadTestShape
+
+# load the user classes of the locustfile of the workload (its own shape classes are replaced by the generated one)
+_locustfile = {{ .Locustfile }}
+sys.path.insert(0, os.path.dirname(_locustfile))
+_spec = importlib.util.spec_from_file_location("keptn_locustfile", _locustfile)
+_module = importlib.util.module_from_spec(_spec)
+_spec.loader.exec_module(_module)
+for _name, _value in vars(_module).items():
+    if _name.startswith("__") or (inspect.isclass(_value) and issubclass(_value, LoadTestShape)):
+        continue
+    globals()[_name] = _value
+
+
+class KeptnLoadShape(LoadTestShape):
+    stages = [
+{{- range .Stages }}
+        {"end": {{ .End }}, "users": {{ .Users }}, "spawn_rate": {{ .SpawnRate }}},
+{{- end }}
+    ]
+
+    def tick(self):
+        run_time = self.get_run_time()
+        for stage in self.stages:
+            if run_time < stage["end"]:
+                return stage["users"], stage["spawn_rate"]
+        return None
+`))
+
+type templateStage struct {
+	End       string
+	Users     int
+	SpawnRate string
+}
+
+// Generate creates a locustfile running the user classes of the given locustfile with the shape of the config
+func Generate(config *Config, locustfile string) ([]byte, error) {
+	stages := []templateStage{}
+	for _, stage := range config.Resolve() {
+		stages = append(stages, templateStage{
+			End:       strconv.FormatFloat(stage.End.Seconds(), 'f', -1, 64),
+			Users:     stage.Users,
+			SpawnRate: strconv.FormatFloat(stage.SpawnRate, 'f', -1, 64),
+		})
+	}
+
+	var output bytes.Buffer
+	err := locustfileTemplate.Execute(&output, map[string]interface{}{
+		// a quoted Go string is a valid Python string literal for file paths
+		"Locustfile": strconv.Quote(locustfile),
+		"Stages":     stages,
+	})
+	return output.Bytes(), err
+}
@@ -0,0 +1,72 @@
+package shape
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	valid := []Config{
+		{Stages: []Stage{{Duration: "1m", Users: 10, SpawnRate: 1}, {Duration: "30s", Users: 0, SpawnRate: 10}}},
+		{Profile: ProfileRamp, Users: 100, Duration: "10m"},
+		{Profile: ProfileStep, Users: 100, Duration: "10m", Steps: 4, SpawnRate: 5},
+	}
+	for _, config := range valid {
+		assert.NoError(t, config.Validate())
+	}
+
+	invalid := []Config{
+		{},
+		{Stages: []Stage{{Duration: "1m", Users: 10, SpawnRate: 1}}, Profile: ProfileRamp},
+		{Stages: []Stage{{Duration: "soon", Users: 10, SpawnRate: 1}}},
+		{Stages: []Stage{{Duration: "1m", Users: -1, SpawnRate: 1}}},
+		{Stages: []Stage{{Duration: "1m", Users: 10}}},
+		{Profile: "wave", Users: 100, Duration: "10m"},
+		{Profile: ProfileSoak, Users: 0, Duration: "10m"},
+		{Profile: ProfileSoak, Users: 10, Duration: "0s"},
+		{Profile: ProfileStep, Users: 10, Duration: "10m", Steps: -1},
+	}
+	for _, config := range invalid {
+		assert.Error(t, config.Validate(), "%+v", config)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	ramp := &Config{Profile: ProfileRamp, Users: 120, Duration: "2m"}
+	assert.Equal(t, []ResolvedStage{{End: 2 * time.Minute, Users: 120, SpawnRate: 1}}, ramp.Resolve())
+
+	step := &Config{Profile: ProfileStep, Users: 100, Duration: "10m", Steps: 4}
+	assert.Equal(t, []ResolvedStage{
+		{End: 150 * time.Second, Users: 25, SpawnRate: 25},
+		{End: 300 * time.Second, Users: 50, SpawnRate: 25},
+		{End: 450 * time.Second, Users: 75, SpawnRate: 25},
+		{End: 600 * time.Second, Users: 100, SpawnRate: 25},
+	}, step.Resolve())
+
+	spike := &Config{Profile: ProfileSpike, Users: 100, Duration: "10m"}
+	assert.Equal(t, []ResolvedStage{
+		{End: 4 * time.Minute, Users: 10, SpawnRate: 10},
+		{End: 6 * time.Minute, Users: 100, SpawnRate: 90},
+		{End: 10 * time.Minute, Users: 10, SpawnRate: 90},
+	}, spike.Resolve())
+	assert.Equal(t, 100, spike.PeakUsers())
+	assert.Equal(t, 10*time.Minute, spike.TotalDuration())
+
+	soak := &Config{Profile: ProfileSoak, Users: 60, Duration: "10m"}
+	assert.Equal(t, []ResolvedStage{
+		{End: time.Minute, Users: 60, SpawnRate: 1},
+		{End: 10 * time.Minute, Users: 60, SpawnRate: 1},
+	}, soak.Resolve())
+}
+
+func TestGenerate(t *testing.T) {
+	config := &Config{Stages: []Stage{{Duration: "1m", Users: 10, SpawnRate: 0.5}, {Duration: "30s", Users: 20, SpawnRate: 2}}}
+
+	locustfile, err := Generate(config, "/tmp/locust-123/locust/load.py")
+	assert.NoError(t, err)
+	assert.Contains(t, string(locustfile), `_locustfile = "/tmp/locust-123/locust/load.py"`)
+	assert.Contains(t, string(locustfile), `{"end": 60, "users": 10, "spawn_rate": 0.5},`)
+	assert.Contains(t, string(locustfile), `{"end": 90, "users": 20, "spawn_rate": 2},`)
+}
@@ -0,0 +1,59 @@
+package sli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+)
+
+// Query is a parsed SLI query of the form "<metric>:<endpoint>", e.g. "p95:GET /carts" or "failure_rate:*"
+type Query struct {
+	Metric   string
+	Endpoint string
+}
+
+// metrics maps the supported metric names to the value they extract from the statistics of an endpoint
+var metrics = map[string]func(r *stats.RequestStats) float64{
+	"requests":      func(r *stats.RequestStats) float64 { return float64(r.RequestCount) },
+	"failures":      func(r *stats.RequestStats) float64 { return float64(r.FailureCount) },
+	"failure_rate":  func(r *stats.RequestStats) float64 { return r.FailureRatio() * 100 },
+	"failure_ratio": func(r *stats.RequestStats) float64 { return r.FailureRatio() },
+	"rps":           func(r *stats.RequestStats) float64 { return r.RequestsPerSecond },
+	"fps":           func(r *stats.RequestStats) float64 { return r.FailuresPerSecond },
+	"avg":           func(r *stats.RequestStats) float64 { return r.AverageResponseTime },
+	"min":           func(r *stats.RequestStats) float64 { return r.MinResponseTime },
+	"max":           func(r *stats.RequestStats) float64 { return r.MaxResponseTime },
+	"median":        func(r *stats.RequestStats) float64 { return r.MedianResponseTime },
+	"p50":           func(r *stats.RequestStats) float64 { return r.P50 },
+	"p90":           func(r *stats.RequestStats) float64 { return r.P90 },
+	"p95":           func(r *stats.RequestStats) float64 { return r.P95 },
+	"p99":           func(r *stats.RequestStats) float64 { return r.P99 },
+}
+
+// ParseQuery parses an SLI query. If no endpoint is given, the query selects the aggregated statistics.
+func ParseQuery(query string) (*Query, error) {
+	parts := strings.SplitN(strings.TrimSpace(query), ":", 2)
+
+	parsed := &Query{
+		Metric:   strings.ToLower(strings.TrimSpace(parts[0])),
+		Endpoint: "*",
+	}
+	if len(parts) == 2 && strings.TrimSpace(parts[1]) != "" {
+		parsed.Endpoint = strings.TrimSpace(parts[1])
+	}
+
+	if _, ok := metrics[parsed.Metric]; !ok {
+		return nil, fmt.Errorf("unsupported metric %q in query %q", parsed.Metric, query)
+	}
+	return parsed, nil
+}
+
+// Value returns the value the query selects from the given statistics
+func (q *Query) Value(statistics *stats.Statistics) (float64, error) {
+	requestStats := statistics.Endpoint(q.Endpoint)
+	if requestStats == nil {
+		return 0, fmt.Errorf("no statistics available for endpoint %q", q.Endpoint)
+	}
+	return metrics[q.Metric](requestStats), nil
+}
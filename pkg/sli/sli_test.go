@@ -0,0 +1,62 @@
+package sli
+
+import (
+	"testing"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func createStatistics() *stats.Statistics {
+	return &stats.Statistics{
+		Endpoints: []stats.RequestStats{
+			{Type: "GET", Name: "/carts", RequestCount: 50, FailureCount: 5, P95: 80},
+		},
+		Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 150, FailureCount: 15, RequestsPerSecond: 15.5, P95: 60},
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	query, err := ParseQuery("p95:GET /carts")
+	assert.NoError(t, err)
+	assert.Equal(t, &Query{Metric: "p95", Endpoint: "GET /carts"}, query)
+
+	query, err = ParseQuery("rps")
+	assert.NoError(t, err)
+	assert.Equal(t, &Query{Metric: "rps", Endpoint: "*"}, query)
+
+	_, err = ParseQuery("p42:*")
+	assert.Error(t, err)
+}
+
+func TestQueryValue(t *testing.T) {
+	statistics := createStatistics()
+
+	tests := []struct {
+		query string
+		value float64
+	}{
+		{"p95:GET /carts", 80},
+		{"p95:/carts", 80},
+		{"failure_rate:*", 10},
+		{"failure_ratio:/carts", 0.1},
+		{"rps:*", 15.5},
+		{"requests", 150},
+	}
+
+	for _, test := range tests {
+		query, err := ParseQuery(test.query)
+		assert.NoError(t, err)
+		value, err := query.Value(statistics)
+		assert.NoError(t, err)
+		assert.Equal(t, test.value, value, test.query)
+	}
+}
+
+func TestQueryValue_UnknownEndpoint(t *testing.T) {
+	query, err := ParseQuery("p95:/unknown")
+	assert.NoError(t, err)
+
+	_, err = query.Value(createStatistics())
+	assert.Error(t, err)
+}
@@ -0,0 +1,266 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AggregatedName is the name locust uses for the row summarizing all requests
+const AggregatedName = "Aggregated"
+
+const (
+	statsSuffix    = "_stats.csv"
+	failuresSuffix = "_failures.csv"
+	historySuffix  = "_stats_history.csv"
+)
+
+// RequestStats is a single row of the locust <prefix>_stats.csv file
+type RequestStats struct {
+	Type                string  `json:"type,omitempty"`
+	Name                string  `json:"name"`
+	RequestCount        int64   `json:"requestCount"`
+	FailureCount        int64   `json:"failureCount"`
+	MedianResponseTime  float64 `json:"medianResponseTime"`
+	AverageResponseTime float64 `json:"averageResponseTime"`
+	MinResponseTime     float64 `json:"minResponseTime"`
+	MaxResponseTime     float64 `json:"maxResponseTime"`
+	AverageContentSize  float64 `json:"averageContentSize"`
+	RequestsPerSecond   float64 `json:"requestsPerSecond"`
+	FailuresPerSecond   float64 `json:"failuresPerSecond"`
+	P50                 float64 `json:"p50"`
+	P90                 float64 `json:"p90"`
+	P95                 float64 `json:"p95"`
+	P99                 float64 `json:"p99"`
+}
+
+// FailureRatio returns the share of failed requests in the range [0, 1]
+func (r RequestStats) FailureRatio() float64 {
+	if r.RequestCount == 0 {
+		return 0
+	}
+	return float64(r.FailureCount) / float64(r.RequestCount)
+}
+
+// Failure is a single row of the locust <prefix>_failures.csv file
+type Failure struct {
+	Method      string `json:"method"`
+	Name        string `json:"name"`
+	Error       string `json:"error"`
+	Occurrences int64  `json:"occurrences"`
+}
+
+// HistoryEntry is a single row of the locust <prefix>_stats_history.csv file
+type HistoryEntry struct {
+	Timestamp         int64   `json:"timestamp"`
+	UserCount         int64   `json:"userCount"`
+	Type              string  `json:"type,omitempty"`
+	Name              string  `json:"name"`
+	RequestsPerSecond float64 `json:"requestsPerSecond"`
+	FailuresPerSecond float64 `json:"failuresPerSecond"`
+	P50               float64 `json:"p50"`
+	P95               float64 `json:"p95"`
+	P99               float64 `json:"p99"`
+	TotalRequestCount int64   `json:"totalRequestCount"`
+	TotalFailureCount int64   `json:"totalFailureCount"`
+}
+
+// Statistics contains everything parsed from the CSV files of a single locust run
+type Statistics struct {
+	Endpoints  []RequestStats `json:"endpoints"`
+	Aggregated RequestStats   `json:"aggregated"`
+	Failures   []Failure      `json:"failures,omitempty"`
+	History    []HistoryEntry `json:"history,omitempty"`
+}
+
+// Summary is the condensed view of a locust run that is attached to the test.finished event
+type Summary struct {
+	RequestCount      int64          `json:"requestCount"`
+	FailureCount      int64          `json:"failureCount"`
+	FailureRatio      float64        `json:"failureRatio"`
+	RequestsPerSecond float64        `json:"requestsPerSecond"`
+	P50               float64        `json:"p50"`
+	P90               float64        `json:"p90"`
+	P95               float64        `json:"p95"`
+	P99               float64        `json:"p99"`
+	Endpoints         []RequestStats `json:"endpoints,omitempty"`
+}
+
+// Summary condenses the statistics to the aggregated values and the per-endpoint rows
+func (s *Statistics) Summary() *Summary {
+	return &Summary{
+		RequestCount:      s.Aggregated.RequestCount,
+		FailureCount:      s.Aggregated.FailureCount,
+		FailureRatio:      s.Aggregated.FailureRatio(),
+		RequestsPerSecond: s.Aggregated.RequestsPerSecond,
+		P50:               s.Aggregated.P50,
+		P90:               s.Aggregated.P90,
+		P95:               s.Aggregated.P95,
+		P99:               s.Aggregated.P99,
+		Endpoints:         s.Endpoints,
+	}
+}
+
+// Endpoint returns the statistics of the endpoint with the given name, or nil if it does not exist.
+// The name can optionally be prefixed with the request type (e.g., "GET /carts"), "*" selects the aggregated statistics.
+func (s *Statistics) Endpoint(name string) *RequestStats {
+	if name == AggregatedName || name == "*" {
+		return &s.Aggregated
+	}
+	for i := range s.Endpoints {
+		if s.Endpoints[i].Name == name || s.Endpoints[i].Type+" "+s.Endpoints[i].Name == name {
+			return &s.Endpoints[i]
+		}
+	}
+	return nil
+}
+
+// ParseStats reads the CSV files locust wrote for the given --csv prefix.
+// The stats file is mandatory, the failures and history files are optional.
+func ParseStats(prefix string) (*Statistics, error) {
+	statistics := &Statistics{}
+
+	rows, err := readCSVFile(prefix + statsSuffix)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		requestStats := RequestStats{
+			Type:                row.getString("Type"),
+			Name:                row.getString("Name"),
+			RequestCount:        row.getInt("Request Count"),
+			FailureCount:        row.getInt("Failure Count"),
+			MedianResponseTime:  row.getFloat("Median Response Time"),
+			AverageResponseTime: row.getFloat("Average Response Time"),
+			MinResponseTime:     row.getFloat("Min Response Time"),
+			MaxResponseTime:     row.getFloat("Max Response Time"),
+			AverageContentSize:  row.getFloat("Average Content Size"),
+			RequestsPerSecond:   row.getFloat("Requests/s"),
+			FailuresPerSecond:   row.getFloat("Failures/s"),
+			P50:                 row.getFloat("50%"),
+			P90:                 row.getFloat("90%"),
+			P95:                 row.getFloat("95%"),
+			P99:                 row.getFloat("99%"),
+		}
+		if requestStats.Name == AggregatedName {
+			statistics.Aggregated = requestStats
+		} else {
+			statistics.Endpoints = append(statistics.Endpoints, requestStats)
+		}
+	}
+
+	rows, err = readCSVFile(prefix + failuresSuffix)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, row := range rows {
+		statistics.Failures = append(statistics.Failures, Failure{
+			Method:      row.getString("Method"),
+			Name:        row.getString("Name"),
+			Error:       row.getString("Error"),
+			Occurrences: row.getInt("Occurrences"),
+		})
+	}
+
+	statistics.History, err = ParseHistory(prefix)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return statistics, nil
+}
+
+// ParseHistory reads the <prefix>_stats_history.csv file. Locust keeps appending to this file while it runs,
+// so it can also be used to inspect the progress of a running test.
+func ParseHistory(prefix string) ([]HistoryEntry, error) {
+	rows, err := readCSVFile(prefix + historySuffix)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []HistoryEntry{}
+	for _, row := range rows {
+		history = append(history, HistoryEntry{
+			Timestamp:         row.getInt("Timestamp"),
+			UserCount:         row.getInt("User Count"),
+			Type:              row.getString("Type"),
+			Name:              row.getString("Name"),
+			RequestsPerSecond: row.getFloat("Requests/s"),
+			FailuresPerSecond: row.getFloat("Failures/s"),
+			P50:               row.getFloat("50%"),
+			P95:               row.getFloat("95%"),
+			P99:               row.getFloat("99%"),
+			TotalRequestCount: row.getInt("Total Request Count"),
+			TotalFailureCount: row.getInt("Total Failure Count"),
+		})
+	}
+	return history, nil
+}
+
+// csvRow maps the header of a CSV file to the values of a single row
+type csvRow map[string]string
+
+func (r csvRow) getString(column string) string {
+	return r[column]
+}
+
+// getInt returns 0 for missing columns and for values locust reports as "N/A"
+func (r csvRow) getInt(column string) int64 {
+	value, err := strconv.ParseInt(r[column], 10, 64)
+	if err != nil {
+		return int64(r.getFloat(column))
+	}
+	return value
+}
+
+// getFloat returns 0 for missing columns and for values locust reports as "N/A"
+func (r csvRow) getFloat(column string) float64 {
+	value, err := strconv.ParseFloat(r[column], 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+func readCSVFile(filename string) ([]csvRow, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rows, err := readCSV(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse %s: %s", filename, err.Error())
+	}
+	return rows, nil
+}
+
+func readCSV(input io.Reader) ([]csvRow, error) {
+	reader := csv.NewReader(input)
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return []csvRow{}, nil
+	}
+
+	header := records[0]
+	rows := make([]csvRow, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := csvRow{}
+		for i, value := range record {
+			if i < len(header) {
+				row[strings.TrimSpace(header[i])] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
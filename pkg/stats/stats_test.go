@@ -0,0 +1,101 @@
+package stats
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const statsCSV = `Type,Name,Request Count,Failure Count,Median Response Time,Average Response Time,Min Response Time,Max Response Time,Average Content Size,Requests/s,Failures/s,50%,66%,75%,80%,90%,95%,98%,99%,99.9%,99.99%,100%
+GET,/,100,0,12,14.5,8,40,512,10.5,0.0,12,13,14,15,20,25,30,35,40,40,40
+GET,/carts,50,5,30,33.1,20,120,128,5.2,0.5,30,31,32,33,60,80,100,110,120,120,120
+,Aggregated,150,5,15,20.7,8,120,384,15.7,0.5,15,16,18,20,40,60,80,100,120,120,120
+`
+
+const failuresCSV = `Method,Name,Error,Occurrences
+GET,/carts,"HTTPError('500 Server Error')",5
+`
+
+const historyCSV = `Timestamp,User Count,Type,Name,Requests/s,Failures/s,50%,66%,75%,80%,90%,95%,98%,99%,99.9%,99.99%,100%,Total Request Count,Total Failure Count,Total Median Response Time,Total Average Response Time,Total Min Response Time,Total Max Response Time,Total Average Content Size
+1625000000,0,,Aggregated,0.000000,0.000000,N/A,N/A,N/A,N/A,N/A,N/A,N/A,N/A,N/A,N/A,N/A,0,0,0,0,0,0,0
+1625000010,10,,Aggregated,15.7,0.5,15,16,18,20,40,60,80,100,120,120,120,150,5,15,20.7,8,120,384
+`
+
+func writeCSVFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for suffix, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, "locust"+suffix), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return filepath.Join(dir, "locust")
+}
+
+func TestParseStats(t *testing.T) {
+	prefix := writeCSVFiles(t, map[string]string{
+		statsSuffix:    statsCSV,
+		failuresSuffix: failuresCSV,
+		historySuffix:  historyCSV,
+	})
+
+	statistics, err := ParseStats(prefix)
+	assert.NoError(t, err)
+	assert.Len(t, statistics.Endpoints, 2)
+	assert.Equal(t, int64(150), statistics.Aggregated.RequestCount)
+	assert.Equal(t, int64(5), statistics.Aggregated.FailureCount)
+	assert.Equal(t, 60.0, statistics.Aggregated.P95)
+
+	carts := statistics.Endpoint("/carts")
+	assert.NotNil(t, carts)
+	assert.Equal(t, "GET", carts.Type)
+	assert.Equal(t, 110.0, carts.P99)
+	assert.Equal(t, 0.1, carts.FailureRatio())
+	assert.Equal(t, carts, statistics.Endpoint("GET /carts"))
+	assert.Nil(t, statistics.Endpoint("POST /carts"))
+	assert.Equal(t, &statistics.Aggregated, statistics.Endpoint("*"))
+
+	assert.Len(t, statistics.Failures, 1)
+	assert.Equal(t, "HTTPError('500 Server Error')", statistics.Failures[0].Error)
+
+	assert.Len(t, statistics.History, 2)
+	assert.Equal(t, 0.0, statistics.History[0].P95)
+	assert.Equal(t, int64(10), statistics.History[1].UserCount)
+}
+
+func TestParseStats_OnlyStatsFile(t *testing.T) {
+	prefix := writeCSVFiles(t, map[string]string{statsSuffix: statsCSV})
+
+	statistics, err := ParseStats(prefix)
+	assert.NoError(t, err)
+	assert.Empty(t, statistics.Failures)
+	assert.Empty(t, statistics.History)
+}
+
+func TestParseStats_MissingStatsFile(t *testing.T) {
+	prefix := writeCSVFiles(t, map[string]string{})
+
+	_, err := ParseStats(prefix)
+	assert.Error(t, err)
+}
+
+func TestSummary(t *testing.T) {
+	prefix := writeCSVFiles(t, map[string]string{statsSuffix: statsCSV})
+
+	statistics, err := ParseStats(prefix)
+	assert.NoError(t, err)
+
+	summary := statistics.Summary()
+	assert.Equal(t, int64(150), summary.RequestCount)
+	assert.InDelta(t, 5.0/150.0, summary.FailureRatio, 0.0001)
+	assert.Equal(t, 15.7, summary.RequestsPerSecond)
+	assert.Equal(t, 100.0, summary.P99)
+	assert.Len(t, summary.Endpoints, 2)
+}
@@ -0,0 +1,137 @@
+package targetlock
+
+import (
+	"context"
+	"sync"
+)
+
+// Manager hands out exclusive locks on test targets, e.g. keyed by project/stage/service. Runs waiting for a target
+// get it in the order they have asked for it. It is safe for concurrent use.
+type Manager struct {
+	mutex   sync.Mutex
+	targets map[string]*target
+}
+
+// target is a locked key with the runs waiting for it
+type target struct {
+	holder  string
+	waiters []*waiter
+}
+
+type waiter struct {
+	owner string
+	ready chan struct{}
+}
+
+// Lock is held by a run until it is released
+type Lock struct {
+	// Key is the locked target
+	Key string
+
+	manager *Manager
+	once    sync.Once
+}
+
+// New creates a Manager without any locks
+func New() *Manager {
+	return &Manager{
+		targets: map[string]*target{},
+	}
+}
+
+// TryAcquire locks the key for the owner, if it is not locked yet. Otherwise it returns nil and the current holder.
+func (m *Manager) TryAcquire(key string, owner string) (*Lock, string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if t, ok := m.targets[key]; ok {
+		return nil, t.holder
+	}
+	m.targets[key] = &target{holder: owner}
+	return m.newLock(key), ""
+}
+
+// Acquire locks the key for the owner and blocks until the key is free. If the key is locked, waiting is called with
+// the current holder and the position of the owner in the queue (starting at 1). If the context is done while
+// waiting, the owner leaves the queue and the error of the context is returned.
+func (m *Manager) Acquire(ctx context.Context, key string, owner string, waiting func(holder string, position int)) (*Lock, error) {
+	m.mutex.Lock()
+	t, ok := m.targets[key]
+	if !ok {
+		m.targets[key] = &target{holder: owner}
+		m.mutex.Unlock()
+		return m.newLock(key), nil
+	}
+	w := &waiter{owner: owner, ready: make(chan struct{})}
+	t.waiters = append(t.waiters, w)
+	holder, position := t.holder, len(t.waiters)
+	m.mutex.Unlock()
+
+	if waiting != nil {
+		waiting(holder, position)
+	}
+
+	select {
+	case <-w.ready:
+		return m.newLock(key), nil
+	case <-ctx.Done():
+	}
+
+	m.mutex.Lock()
+	for i, queued := range t.waiters {
+		if queued == w {
+			t.waiters = append(t.waiters[:i], t.waiters[i+1:]...)
+			m.mutex.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+	m.mutex.Unlock()
+
+	// the lock has been handed over right before the context was done, so it is passed on
+	m.release(key)
+	return nil, ctx.Err()
+}
+
+// Holder returns the owner holding the key, or an empty string if it is not locked
+func (m *Manager) Holder(key string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if t, ok := m.targets[key]; ok {
+		return t.holder
+	}
+	return ""
+}
+
+func (m *Manager) newLock(key string) *Lock {
+	return &Lock{
+		Key:     key,
+		manager: m,
+	}
+}
+
+// release hands the key over to the first waiting owner, or unlocks it if nobody is waiting
+func (m *Manager) release(key string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	t, ok := m.targets[key]
+	if !ok {
+		return
+	}
+	if len(t.waiters) == 0 {
+		delete(m.targets, key)
+		return
+	}
+	next := t.waiters[0]
+	t.waiters = t.waiters[1:]
+	t.holder = next.owner
+	close(next.ready)
+}
+
+// Release unlocks the target, only the first call has an effect
+func (l *Lock) Release() {
+	l.once.Do(func() {
+		l.manager.release(l.Key)
+	})
+}
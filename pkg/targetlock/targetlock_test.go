@@ -0,0 +1,77 @@
+package targetlock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTryAcquire(t *testing.T) {
+	manager := New()
+
+	lock, holder := manager.TryAcquire("sockshop/dev/carts", "first")
+	assert.NotNil(t, lock)
+	assert.Equal(t, "", holder)
+
+	second, holder := manager.TryAcquire("sockshop/dev/carts", "second")
+	assert.Nil(t, second)
+	assert.Equal(t, "first", holder)
+
+	// other targets are independent
+	other, _ := manager.TryAcquire("sockshop/staging/carts", "second")
+	assert.NotNil(t, other)
+
+	lock.Release()
+	lock.Release()
+	assert.Equal(t, "", manager.Holder("sockshop/dev/carts"))
+}
+
+func TestAcquire_Queue(t *testing.T) {
+	manager := New()
+	first, err := manager.Acquire(context.Background(), "target", "first", nil)
+	assert.NoError(t, err)
+
+	acquired := make(chan string, 2)
+	positions := make(chan int, 2)
+	for _, owner := range []string{"second", "third"} {
+		go func(owner string) {
+			lock, err := manager.Acquire(context.Background(), "target", owner, func(holder string, position int) {
+				assert.Equal(t, "first", holder)
+				positions <- position
+			})
+			assert.NoError(t, err)
+			acquired <- owner
+			lock.Release()
+		}(owner)
+		// the second run is queued before the third one
+		assert.Equal(t, map[string]int{"second": 1, "third": 2}[owner], <-positions)
+	}
+
+	select {
+	case owner := <-acquired:
+		t.Fatalf("%s acquired the target while it is locked", owner)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	first.Release()
+	assert.Equal(t, "second", <-acquired)
+	assert.Equal(t, "third", <-acquired)
+	assert.Equal(t, "", manager.Holder("target"))
+}
+
+func TestAcquire_Canceled(t *testing.T) {
+	manager := New()
+	first, err := manager.Acquire(context.Background(), "target", "first", nil)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = manager.Acquire(ctx, "target", "second", nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+
+	// the canceled run has left the queue
+	first.Release()
+	assert.Equal(t, "", manager.Holder("target"))
+}
@@ -0,0 +1,116 @@
+package thresholds
+
+import (
+	"fmt"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+)
+
+// Severity defines how a violated threshold affects the result of a test run
+type Severity string
+
+const (
+	// SeverityFail marks the test run as failed if the threshold is violated
+	SeverityFail Severity = "fail"
+	// SeverityWarning marks the test run with a warning if the threshold is violated
+	SeverityWarning Severity = "warning"
+)
+
+// Threshold defines the limits a locust run has to stay within. If Endpoint is empty the limits
+// are checked against the aggregated statistics, otherwise against the endpoint with that name.
+type Threshold struct {
+	Endpoint        string   `json:"endpoint" yaml:"endpoint"`
+	Severity        Severity `json:"severity" yaml:"severity"`
+	MaxFailureRatio *float64 `json:"max_failure_ratio" yaml:"max_failure_ratio"`
+	MaxP95          *float64 `json:"max_p95" yaml:"max_p95"`
+	MaxP99          *float64 `json:"max_p99" yaml:"max_p99"`
+	MinRPS          *float64 `json:"min_rps" yaml:"min_rps"`
+}
+
+// Violation describes a single threshold that has not been met
+type Violation struct {
+	Endpoint string   `json:"endpoint"`
+	Metric   string   `json:"metric"`
+	Limit    float64  `json:"limit"`
+	Actual   float64  `json:"actual"`
+	Severity Severity `json:"severity"`
+}
+
+func (v Violation) String() string {
+	switch v.Metric {
+	case "requests":
+		return fmt.Sprintf("[%s] no requests recorded for %s", v.Severity, v.Endpoint)
+	case "min_rps":
+		return fmt.Sprintf("[%s] %s of %s is %.2f (min %.2f)", v.Severity, v.Metric, v.Endpoint, v.Actual, v.Limit)
+	default:
+		return fmt.Sprintf("[%s] %s of %s is %.2f (max %.2f)", v.Severity, v.Metric, v.Endpoint, v.Actual, v.Limit)
+	}
+}
+
+// Validate checks that the threshold has a known severity and at least one limit
+func (t *Threshold) Validate() error {
+	if t.Severity != "" && t.Severity != SeverityFail && t.Severity != SeverityWarning {
+		return fmt.Errorf("unknown severity %q, expected %q or %q", t.Severity, SeverityFail, SeverityWarning)
+	}
+	if t.MaxFailureRatio == nil && t.MaxP95 == nil && t.MaxP99 == nil && t.MinRPS == nil {
+		return fmt.Errorf("threshold for %s does not define any limit", t.endpointName())
+	}
+	return nil
+}
+
+func (t *Threshold) severity() Severity {
+	if t.Severity == "" {
+		return SeverityFail
+	}
+	return t.Severity
+}
+
+func (t *Threshold) endpointName() string {
+	if t.Endpoint == "" || t.Endpoint == "*" {
+		return stats.AggregatedName
+	}
+	return t.Endpoint
+}
+
+// Evaluate checks all thresholds against the statistics of a locust run and returns every violation
+func Evaluate(thresholds []*Threshold, statistics *stats.Statistics) []Violation {
+	violations := []Violation{}
+
+	for _, threshold := range thresholds {
+		endpoint := threshold.endpointName()
+		severity := threshold.severity()
+
+		requestStats := statistics.Endpoint(endpoint)
+		if requestStats == nil {
+			violations = append(violations, Violation{Endpoint: endpoint, Metric: "requests", Severity: severity})
+			continue
+		}
+
+		if threshold.MaxFailureRatio != nil && requestStats.FailureRatio() > *threshold.MaxFailureRatio {
+			violations = append(violations, Violation{endpoint, "failure_ratio", *threshold.MaxFailureRatio, requestStats.FailureRatio(), severity})
+		}
+		if threshold.MaxP95 != nil && requestStats.P95 > *threshold.MaxP95 {
+			violations = append(violations, Violation{endpoint, "p95", *threshold.MaxP95, requestStats.P95, severity})
+		}
+		if threshold.MaxP99 != nil && requestStats.P99 > *threshold.MaxP99 {
+			violations = append(violations, Violation{endpoint, "p99", *threshold.MaxP99, requestStats.P99, severity})
+		}
+		if threshold.MinRPS != nil && requestStats.RequestsPerSecond < *threshold.MinRPS {
+			violations = append(violations, Violation{endpoint, "min_rps", *threshold.MinRPS, requestStats.RequestsPerSecond, severity})
+		}
+	}
+
+	return violations
+}
+
+// HighestSeverity returns the most severe severity of the given violations, or an empty string if there are none
+func HighestSeverity(violations []Violation) Severity {
+	var highest Severity
+	for _, violation := range violations {
+		if violation.Severity == SeverityFail {
+			return SeverityFail
+		}
+		highest = violation.Severity
+	}
+	return highest
+}
@@ -0,0 +1,75 @@
+package thresholds
+
+import (
+	"testing"
+
+	"github.com/keptn-sandbox/locust-service/pkg/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func float(value float64) *float64 {
+	return &value
+}
+
+func createStatistics() *stats.Statistics {
+	return &stats.Statistics{
+		Endpoints: []stats.RequestStats{
+			{Type: "GET", Name: "/", RequestCount: 100, FailureCount: 0, RequestsPerSecond: 10, P95: 25, P99: 35},
+			{Type: "GET", Name: "/carts", RequestCount: 50, FailureCount: 5, RequestsPerSecond: 5, P95: 80, P99: 110},
+		},
+		Aggregated: stats.RequestStats{Name: stats.AggregatedName, RequestCount: 150, FailureCount: 5, RequestsPerSecond: 15, P95: 60, P99: 100},
+	}
+}
+
+func TestEvaluate_NoViolations(t *testing.T) {
+	thresholds := []*Threshold{
+		{MaxFailureRatio: float(0.05), MaxP95: float(100), MinRPS: float(10)},
+		{Endpoint: "/", MaxP99: float(50)},
+	}
+
+	violations := Evaluate(thresholds, createStatistics())
+	assert.Empty(t, violations)
+	assert.Equal(t, Severity(""), HighestSeverity(violations))
+}
+
+func TestEvaluate_Violations(t *testing.T) {
+	thresholds := []*Threshold{
+		{Endpoint: "*", MinRPS: float(20), Severity: SeverityWarning},
+		{Endpoint: "/carts", MaxFailureRatio: float(0.01), MaxP95: float(50), MaxP99: float(200)},
+	}
+
+	violations := Evaluate(thresholds, createStatistics())
+	assert.Len(t, violations, 3)
+	assert.Equal(t, Violation{stats.AggregatedName, "min_rps", 20, 15, SeverityWarning}, violations[0])
+	assert.Equal(t, "failure_ratio", violations[1].Metric)
+	assert.Equal(t, "/carts", violations[1].Endpoint)
+	assert.Equal(t, "p95", violations[2].Metric)
+	assert.Equal(t, SeverityFail, HighestSeverity(violations))
+}
+
+func TestEvaluate_OnlyWarnings(t *testing.T) {
+	thresholds := []*Threshold{
+		{MaxP99: float(50), Severity: SeverityWarning},
+	}
+
+	violations := Evaluate(thresholds, createStatistics())
+	assert.Len(t, violations, 1)
+	assert.Equal(t, "[warning] p99 of Aggregated is 100.00 (max 50.00)", violations[0].String())
+	assert.Equal(t, SeverityWarning, HighestSeverity(violations))
+}
+
+func TestEvaluate_UnknownEndpoint(t *testing.T) {
+	thresholds := []*Threshold{
+		{Endpoint: "/unknown", MaxP95: float(100)},
+	}
+
+	violations := Evaluate(thresholds, createStatistics())
+	assert.Len(t, violations, 1)
+	assert.Equal(t, "requests", violations[0].Metric)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, (&Threshold{MaxP95: float(100)}).Validate())
+	assert.Error(t, (&Threshold{}).Validate())
+	assert.Error(t, (&Threshold{MaxP95: float(100), Severity: "critical"}).Validate())
+}
@@ -0,0 +1,271 @@
+package virtualenv
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/keptn-sandbox/locust-service/pkg/logstream"
+)
+
+const (
+	// DefaultPython is the interpreter the virtualenvs are created with if nothing else is configured
+	DefaultPython = "python3"
+	// RequirementsFilename is the copy of the requirements kept within every virtualenv
+	RequirementsFilename = "requirements.txt"
+	// readyFilename marks a virtualenv whose requirements have been installed, its modification time is the last use
+	readyFilename = ".ready"
+	// installOutputLimit is the number of bytes at the end of the output of pip that is kept for the report
+	installOutputLimit = 4 * 1024
+)
+
+// Manager creates virtualenvs for requirements files and caches them, keyed by the hash of the requirements.
+// Only the least recently used virtualenvs are kept. It is safe for concurrent use.
+type Manager struct {
+	// Dir holds one directory per virtualenv
+	Dir string
+	// Python is the interpreter the virtualenvs are created with
+	Python string
+	// MaxEnvironments is the number of virtualenvs that are kept, 0 keeps all of them
+	MaxEnvironments int
+	// Output receives the output of venv and pip while they are running (optional)
+	Output io.Writer
+
+	// mutex guards inUse and creating, the virtualenvs themselves are guarded by the locks in creating
+	mutex    sync.Mutex
+	inUse    map[string]int
+	creating map[string]*hashLock
+}
+
+// hashLock serializes the creation of the virtualenv for one hash, users counts the holders and waiters
+type hashLock struct {
+	mutex sync.Mutex
+	users int
+}
+
+// NewManager creates a Manager keeping at most maxEnvironments virtualenvs in the given directory
+func NewManager(dir string, maxEnvironments int) *Manager {
+	return &Manager{
+		Dir:             dir,
+		Python:          DefaultPython,
+		MaxEnvironments: maxEnvironments,
+		inUse:           map[string]int{},
+		creating:        map[string]*hashLock{},
+	}
+}
+
+// Environment is a virtualenv with the requirements installed, it must be released once it is no longer used
+type Environment struct {
+	// Hash identifies the requirements the virtualenv has been created for
+	Hash string
+	// Dir is the root directory of the virtualenv
+	Dir string
+	// Created is true if the virtualenv has been created for this use, false if it has been reused from the cache
+	Created bool
+	// Output is the end of the output of pip, if the virtualenv has been created
+	Output string
+
+	release sync.Once
+	manager *Manager
+}
+
+// Python returns the interpreter of the virtualenv
+func (e *Environment) Python() string {
+	return filepath.Join(e.Dir, "bin", "python")
+}
+
+// Release allows the virtualenv to be evicted from the cache again
+func (e *Environment) Release() {
+	e.release.Do(func() {
+		e.manager.mutex.Lock()
+		defer e.manager.mutex.Unlock()
+		e.manager.release(e.Hash)
+	})
+}
+
+// release decrements the uses of the virtualenv, the mutex must be held
+func (m *Manager) release(hash string) {
+	m.inUse[hash]--
+	if m.inUse[hash] <= 0 {
+		delete(m.inUse, hash)
+	}
+}
+
+// lock waits until no other virtualenv with the hash is being created, the virtualenv is not evicted until unlocked
+func (m *Manager) lock(hash string) (unlock func()) {
+	m.mutex.Lock()
+	lock, ok := m.creating[hash]
+	if !ok {
+		lock = &hashLock{}
+		m.creating[hash] = lock
+	}
+	lock.users++
+	m.mutex.Unlock()
+
+	lock.mutex.Lock()
+	return func() {
+		lock.mutex.Unlock()
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		lock.users--
+		if lock.users == 0 {
+			delete(m.creating, hash)
+		}
+	}
+}
+
+// Hash returns the key of the virtualenv for the given requirements
+func Hash(requirements []byte) string {
+	sum := sha256.Sum256(requirements)
+	return hex.EncodeToString(sum[:])
+}
+
+// Ensure returns the virtualenv for the requirements, it is created and the requirements are installed if it is
+// not cached yet. Virtualenvs exceeding MaxEnvironments are evicted afterwards, their hashes are returned.
+// The error of a failed creation contains the end of the output of pip.
+// Tests with the same requirements wait for a running installation instead of installing them twice, while
+// virtualenvs for other requirements can be created or taken from the cache meanwhile.
+func (m *Manager) Ensure(ctx context.Context, requirements []byte) (*Environment, []string, error) {
+	hash := Hash(requirements)
+	environment := &Environment{
+		Hash:    hash,
+		Dir:     filepath.Join(m.Dir, hash),
+		manager: m,
+	}
+
+	unlock := m.lock(hash)
+	defer unlock()
+
+	readyFile := filepath.Join(environment.Dir, readyFilename)
+	if _, err := os.Stat(readyFile); err != nil {
+		// the virtualenv does not exist yet or its creation has not been completed
+		output, err := m.create(ctx, environment.Dir, requirements)
+		if err != nil {
+			os.RemoveAll(environment.Dir)
+			return nil, nil, err
+		}
+		environment.Created = true
+		environment.Output = output
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	if err := os.Chtimes(readyFile, now, now); err != nil {
+		return nil, nil, fmt.Errorf("could not mark virtualenv %s as used: %w", hash, err)
+	}
+	m.inUse[hash]++
+
+	evicted, err := m.evict()
+	if err != nil {
+		m.release(hash)
+		return nil, nil, err
+	}
+	return environment, evicted, nil
+}
+
+// create creates the virtualenv in dir and installs the requirements into it, returning the end of the output
+func (m *Manager) create(ctx context.Context, dir string, requirements []byte) (string, error) {
+	if err := os.RemoveAll(dir); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(m.Dir, 0755); err != nil {
+		return "", err
+	}
+
+	output := logstream.NewRing(installOutputLimit)
+	writer := io.Writer(output)
+	if m.Output != nil {
+		writer = io.MultiWriter(output, m.Output)
+	}
+
+	// the packages of the service (e.g. locust itself) remain available within the virtualenv
+	if err := m.run(ctx, writer, m.Python, "-m", "venv", "--system-site-packages", dir); err != nil {
+		return output.String(), fmt.Errorf("could not create virtualenv: %w\n%s", err, output.String())
+	}
+
+	requirementsFile := filepath.Join(dir, RequirementsFilename)
+	if err := ioutil.WriteFile(requirementsFile, requirements, 0644); err != nil {
+		return output.String(), err
+	}
+
+	python := filepath.Join(dir, "bin", "python")
+	if err := m.run(ctx, writer, python, "-m", "pip", "install", "--disable-pip-version-check", "--no-input", "-r", requirementsFile); err != nil {
+		return output.String(), fmt.Errorf("could not install requirements: %w\n%s", err, output.String())
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, readyFilename), []byte{}, 0644); err != nil {
+		return output.String(), err
+	}
+	return output.String(), nil
+}
+
+func (m *Manager) run(ctx context.Context, output io.Writer, command string, args ...string) error {
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	return cmd.Run()
+}
+
+// evict removes the least recently used virtualenvs beyond MaxEnvironments, virtualenvs in use or being created are
+// kept. The mutex must be held.
+func (m *Manager) evict() ([]string, error) {
+	if m.MaxEnvironments <= 0 {
+		return nil, nil
+	}
+
+	entries, err := ioutil.ReadDir(m.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type cached struct {
+		hash     string
+		lastUsed time.Time
+	}
+	environments := []cached{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(m.Dir, entry.Name(), readyFilename))
+		if err != nil {
+			// left over by an interrupted creation
+			info = entry
+		}
+		environments = append(environments, cached{hash: entry.Name(), lastUsed: info.ModTime()})
+	}
+
+	// most recently used first
+	sort.Slice(environments, func(i, j int) bool {
+		return environments[i].lastUsed.After(environments[j].lastUsed)
+	})
+
+	// virtualenvs in use are always kept, the remaining space is left to the most recently used ones
+	kept := len(m.inUse)
+	evicted := []string{}
+	for _, environment := range environments {
+		if m.inUse[environment.hash] > 0 || m.creating[environment.hash] != nil {
+			continue
+		}
+		if kept < m.MaxEnvironments {
+			kept++
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(m.Dir, environment.hash)); err != nil {
+			return evicted, fmt.Errorf("could not evict virtualenv %s: %w", environment.hash, err)
+		}
+		evicted = append(evicted, environment.hash)
+	}
+	return evicted, nil
+}
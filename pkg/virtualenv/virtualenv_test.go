@@ -0,0 +1,145 @@
+package virtualenv
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// createManager returns a Manager using a shell script instead of python, which copies itself into the virtualenvs
+// and fails to install requirements containing "broken". Requirements containing "slow" create the file "installing"
+// in the returned directory and wait for the file "continue" there. Every installation is appended to the returned file.
+func createManager(t *testing.T, maxEnvironments int) (*Manager, string) {
+	dir, err := ioutil.TempDir("", "virtualenv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	installs := filepath.Join(dir, "installs")
+	script := `#!/bin/sh
+case "$2" in
+  venv) mkdir -p "$4/bin" && cp "$0" "$4/bin/python" ;;
+  pip)
+    for requirements; do :; done
+    if grep -q broken "$requirements"; then echo "ERROR: No matching distribution found for broken"; exit 1; fi
+    if grep -q slow "$requirements"; then
+      touch ` + filepath.Join(dir, "installing") + `
+      while [ ! -f ` + filepath.Join(dir, "continue") + ` ]; do sleep 0.01; done
+    fi
+    cat "$requirements" >> ` + installs + `
+    echo "Successfully installed $(cat "$requirements")" ;;
+esac
+`
+	python := filepath.Join(dir, "python")
+	if err := ioutil.WriteFile(python, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewManager(filepath.Join(dir, "virtualenvs"), maxEnvironments)
+	manager.Python = python
+	return manager, installs
+}
+
+func TestEnsure(t *testing.T) {
+	manager, installs := createManager(t, 0)
+
+	environment, evicted, err := manager.Ensure(context.Background(), []byte("faker\n"))
+	assert.NoError(t, err)
+	assert.Empty(t, evicted)
+	assert.True(t, environment.Created)
+	assert.Equal(t, filepath.Join(manager.Dir, Hash([]byte("faker\n"))), environment.Dir)
+	assert.Equal(t, filepath.Join(environment.Dir, "bin", "python"), environment.Python())
+	assert.Contains(t, environment.Output, "Successfully installed faker")
+	environment.Release()
+
+	// the same requirements reuse the virtualenv
+	environment, _, err = manager.Ensure(context.Background(), []byte("faker\n"))
+	assert.NoError(t, err)
+	assert.False(t, environment.Created)
+	environment.Release()
+
+	content, err := ioutil.ReadFile(installs)
+	assert.NoError(t, err)
+	assert.Equal(t, "faker\n", string(content))
+}
+
+func TestEnsure_Failed(t *testing.T) {
+	manager, _ := createManager(t, 0)
+
+	_, _, err := manager.Ensure(context.Background(), []byte("broken\n"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No matching distribution found for broken")
+
+	// the incomplete virtualenv is removed
+	_, err = os.Stat(filepath.Join(manager.Dir, Hash([]byte("broken\n"))))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestEnsure_Evict(t *testing.T) {
+	manager, _ := createManager(t, 2)
+
+	first, _, err := manager.Ensure(context.Background(), []byte("first\n"))
+	assert.NoError(t, err)
+	second, _, err := manager.Ensure(context.Background(), []byte("second\n"))
+	assert.NoError(t, err)
+	second.Release()
+
+	// the first virtualenv is still in use, so the second one is evicted although it has been used more recently
+	_, evicted, err := manager.Ensure(context.Background(), []byte("third\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{second.Hash}, evicted)
+	first.Release()
+
+	_, evicted, err = manager.Ensure(context.Background(), []byte("fourth\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{first.Hash}, evicted)
+
+	entries, err := ioutil.ReadDir(manager.Dir)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{Hash([]byte("third\n")), Hash([]byte("fourth\n"))}, names)
+}
+
+func TestEnsure_Concurrent(t *testing.T) {
+	manager, installs := createManager(t, 0)
+	dir := filepath.Dir(installs)
+
+	cached, _, err := manager.Ensure(context.Background(), []byte("faker\n"))
+	assert.NoError(t, err)
+
+	created := make(chan *Environment)
+	go func() {
+		environment, _, err := manager.Ensure(context.Background(), []byte("slow\n"))
+		assert.NoError(t, err)
+		created <- environment
+	}()
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "installing"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// cached virtualenvs can be used and released while other requirements are being installed
+	environment, _, err := manager.Ensure(context.Background(), []byte("faker\n"))
+	assert.NoError(t, err)
+	assert.False(t, environment.Created)
+	environment.Release()
+	cached.Release()
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "continue"), []byte{}, 0644))
+	environment = <-created
+	assert.True(t, environment.Created)
+	environment.Release()
+
+	content, err := ioutil.ReadFile(installs)
+	assert.NoError(t, err)
+	assert.Equal(t, "faker\nslow\n", string(content))
+}
@@ -0,0 +1,155 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull is returned by Submit if all workers are busy and the queue has reached its capacity
+var ErrQueueFull = errors.New("queue is full")
+
+// ErrClosed is returned by Submit once the pool has been closed
+var ErrClosed = errors.New("worker pool is closed")
+
+// Pool runs submitted tasks on a fixed number of workers in the order they have been submitted.
+// Tasks that cannot be started immediately wait in a queue with a limited capacity.
+// A task can give up its worker while it is waiting for something else, see Idle.
+type Pool struct {
+	workers  int
+	capacity int
+
+	mutex sync.Mutex
+	// assigned counts the tasks that have been submitted but not finished yet (running, idle and queued)
+	assigned int
+	// busy counts the tasks holding a worker
+	busy int
+	// queued holds the submitted tasks waiting for a worker in order
+	queued []chan struct{}
+	// resuming holds the idle tasks waiting for a worker again, they get one before the queued tasks
+	resuming []chan struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewPool creates a new Pool
+func NewPool(workers int, capacity int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if capacity < 0 {
+		capacity = 0
+	}
+
+	return &Pool{
+		workers:  workers,
+		capacity: capacity,
+	}
+}
+
+// Submit queues the task and returns its position in the queue, 0 means the task is started right away
+func (p *Pool) Submit(task func()) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return 0, ErrClosed
+	}
+	if p.assigned >= p.workers+p.capacity {
+		return 0, ErrQueueFull
+	}
+
+	position := 0
+	var ready chan struct{}
+	if p.busy < p.workers {
+		p.busy++
+	} else {
+		ready = make(chan struct{})
+		p.queued = append(p.queued, ready)
+		position = len(p.queued)
+	}
+	p.assigned++
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		if ready != nil {
+			<-ready
+		}
+		task()
+
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		p.assigned--
+		p.release()
+	}()
+	return position, nil
+}
+
+// Idle releases the worker of the calling task while wait is running, so a queued task can use it meanwhile.
+// Afterwards the task waits for a worker again, ahead of the queued tasks. It must only be called by tasks of the pool.
+func (p *Pool) Idle(wait func()) {
+	p.mutex.Lock()
+	p.release()
+	p.mutex.Unlock()
+
+	wait()
+
+	p.mutex.Lock()
+	if p.busy < p.workers {
+		p.busy++
+		p.mutex.Unlock()
+		return
+	}
+	ready := make(chan struct{})
+	p.resuming = append(p.resuming, ready)
+	p.mutex.Unlock()
+	<-ready
+}
+
+// release passes the worker on to the next waiting task, the mutex must be held
+func (p *Pool) release() {
+	var next chan struct{}
+	if len(p.resuming) > 0 {
+		next, p.resuming = p.resuming[0], p.resuming[1:]
+	} else if len(p.queued) > 0 {
+		next, p.queued = p.queued[0], p.queued[1:]
+	} else {
+		p.busy--
+		return
+	}
+	close(next)
+}
+
+// Queued returns the number of tasks waiting for a worker
+func (p *Pool) Queued() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return len(p.queued) + len(p.resuming)
+}
+
+// Close stops accepting new tasks and waits until all submitted tasks have finished
+func (p *Pool) Close() {
+	p.mutex.Lock()
+	p.closed = true
+	p.mutex.Unlock()
+
+	p.wg.Wait()
+}
+
+type contextKey struct{}
+
+// NewContext returns a context for a task of the pool, which lets the task call Idle through the context
+func NewContext(ctx context.Context, pool *Pool) context.Context {
+	return context.WithValue(ctx, contextKey{}, pool)
+}
+
+// Idle runs wait, releasing the worker of the task meanwhile if the context has been created by NewContext
+func Idle(ctx context.Context, wait func()) {
+	if pool, ok := ctx.Value(contextKey{}).(*Pool); ok && pool != nil {
+		pool.Idle(wait)
+		return
+	}
+	wait()
+}
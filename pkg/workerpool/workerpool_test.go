@@ -0,0 +1,99 @@
+package workerpool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool(t *testing.T) {
+	pool := NewPool(2, 2)
+
+	release := make(chan struct{})
+	var mutex sync.Mutex
+	finished := []int{}
+	task := func(id int) func() {
+		return func() {
+			<-release
+			mutex.Lock()
+			defer mutex.Unlock()
+			finished = append(finished, id)
+		}
+	}
+
+	for id, expected := range []int{0, 0, 1, 2} {
+		position, err := pool.Submit(task(id))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, position)
+	}
+	assert.Equal(t, 2, pool.Queued())
+
+	_, err := pool.Submit(task(4))
+	assert.Equal(t, ErrQueueFull, err)
+
+	close(release)
+	pool.Close()
+
+	assert.Len(t, finished, 4)
+	assert.Equal(t, 0, pool.Queued())
+
+	_, err = pool.Submit(task(5))
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestPool_FreesSlots(t *testing.T) {
+	pool := NewPool(1, 0)
+	defer pool.Close()
+
+	done := make(chan struct{})
+	position, err := pool.Submit(func() { close(done) })
+	assert.NoError(t, err)
+	assert.Equal(t, 0, position)
+	<-done
+
+	// the slot of the finished task becomes available again
+	assert.Eventually(t, func() bool {
+		_, err := pool.Submit(func() {})
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestPool_Idle(t *testing.T) {
+	pool := NewPool(1, 1)
+	defer pool.Close()
+
+	unblock := make(chan struct{})
+	resumed := make(chan struct{})
+	_, err := pool.Submit(func() {
+		Idle(NewContext(context.Background(), pool), func() { <-unblock })
+		close(resumed)
+	})
+	assert.NoError(t, err)
+
+	// the idle task leaves its worker to the queued task
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	_, err = pool.Submit(func() {
+		close(started)
+		<-finish
+	})
+	assert.NoError(t, err)
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("queued task has not been started while the other task is idle")
+	}
+
+	// the idle task gets the worker back once the running task has finished
+	close(unblock)
+	assert.Eventually(t, func() bool { return pool.Queued() == 1 }, time.Second, 5*time.Millisecond)
+	close(finish)
+	select {
+	case <-resumed:
+	case <-time.After(time.Second):
+		t.Fatal("idle task has not been resumed")
+	}
+}
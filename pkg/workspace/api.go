@@ -0,0 +1,66 @@
+package workspace
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/keptn-sandbox/locust-service/pkg/httpjson"
+)
+
+// WorkspacesPath is the path under which the workspaces are served
+const WorkspacesPath = "/workspaces"
+
+// Handler serves the workspaces via HTTP:
+//
+//	GET /workspaces
+//	GET /workspaces/<name> (including the files of the workspace)
+//	DELETE /workspaces/<name> (unless its test is still running)
+type Handler struct {
+	Manager *Manager
+}
+
+// NewHandler creates a new Handler for the given manager
+func NewHandler(manager *Manager) *Handler {
+	return &Handler{
+		Manager: manager,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, WorkspacesPath), "/")
+
+	switch {
+	case r.Method == http.MethodGet && name == "":
+		infos, err := h.Manager.List()
+		if err != nil {
+			httpjson.Error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		httpjson.Write(w, http.StatusOK, infos)
+	case r.Method == http.MethodGet:
+		info, err := h.Manager.Get(name)
+		if err != nil {
+			httpjson.Error(w, statusOf(err), err.Error())
+			return
+		}
+		httpjson.Write(w, http.StatusOK, info)
+	case r.Method == http.MethodDelete && name != "":
+		if err := h.Manager.Remove(name); err != nil {
+			httpjson.Error(w, statusOf(err), err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		httpjson.Error(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func statusOf(err error) int {
+	switch err {
+	case ErrWorkspaceNotFound:
+		return http.StatusNotFound
+	case ErrWorkspaceActive:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
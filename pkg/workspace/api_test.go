@@ -0,0 +1,51 @@
+package workspace
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	manager := createManager(t, Policy{Cleanup: CleanupNever})
+	active, err := manager.Create("active", "triggered-1")
+	assert.NoError(t, err)
+	kept, err := manager.Create("kept", "triggered-2")
+	assert.NoError(t, err)
+	assert.NoError(t, kept.Finish(false))
+
+	handler := NewHandler(manager)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/workspaces", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	infos := []*Info{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &infos))
+	assert.Len(t, infos, 2)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/workspaces/kept", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	info := &Info{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), info))
+	assert.Equal(t, "triggered-2", info.TriggeredID)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/workspaces/unknown", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/workspaces/"+active.Name(), nil))
+	assert.Equal(t, http.StatusConflict, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/workspaces/kept", nil))
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/workspaces", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
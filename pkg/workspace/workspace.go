@@ -0,0 +1,371 @@
+package workspace
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// CleanupAlways removes every workspace once its test has finished
+	CleanupAlways = "always"
+	// CleanupOnSuccess removes the workspaces of successful tests, the ones of failed tests are kept for debugging
+	CleanupOnSuccess = "on-success"
+	// CleanupNever keeps every workspace, so only KeepLast and MaxAge remove them
+	CleanupNever = "never"
+
+	// MetadataFilename is the file within every workspace describing it
+	MetadataFilename = ".workspace.json"
+)
+
+// ErrWorkspaceNotFound is returned if there is no workspace with the requested name
+var ErrWorkspaceNotFound = errors.New("workspace not found")
+
+// ErrWorkspaceActive is returned if a workspace cannot be removed because its test is still running
+var ErrWorkspaceActive = errors.New("workspace is in use")
+
+var invalidNameCharacters = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// Policy defines which workspaces are removed
+type Policy struct {
+	// Cleanup defines whether a workspace is removed once its test has finished: always, on-success or never
+	Cleanup string
+	// KeepLast is the number of kept workspaces that are retained, older ones are removed (0 retains all)
+	KeepLast int
+	// MaxAge is the time after which kept workspaces are removed (0 retains them regardless of their age)
+	MaxAge time.Duration
+}
+
+// Validate checks the settings of the policy
+func (p Policy) Validate() error {
+	switch p.Cleanup {
+	case CleanupAlways, CleanupOnSuccess, CleanupNever:
+	default:
+		return fmt.Errorf("invalid cleanup policy %s, must be %s, %s or %s", p.Cleanup, CleanupAlways, CleanupOnSuccess, CleanupNever)
+	}
+	if p.KeepLast < 0 {
+		return fmt.Errorf("invalid number of workspaces to keep: %d", p.KeepLast)
+	}
+	if p.MaxAge < 0 {
+		return fmt.Errorf("invalid maximum age of workspaces: %s", p.MaxAge)
+	}
+	return nil
+}
+
+// Info describes a workspace
+type Info struct {
+	Name         string     `json:"name"`
+	KeptnContext string     `json:"keptnContext"`
+	TriggeredID  string     `json:"triggeredId"`
+	Created      time.Time  `json:"created"`
+	Finished     *time.Time `json:"finished,omitempty"`
+	Succeeded    bool       `json:"succeeded"`
+	// Active is set while the test of the workspace is running
+	Active bool `json:"active"`
+	// Files holds the files of the workspace (relative to it), it is only set by Manager.Get
+	Files []string `json:"files,omitempty"`
+}
+
+// lastUsed returns when the workspace has been finished, or created if it has never been finished
+func (i *Info) lastUsed() time.Time {
+	if i.Finished != nil {
+		return *i.Finished
+	}
+	return i.Created
+}
+
+// Workspace is the directory a single test works in
+type Workspace struct {
+	// Dir is the directory of the workspace
+	Dir string
+
+	info    Info
+	manager *Manager
+	once    sync.Once
+}
+
+// Name returns the name of the workspace, which is the name of its directory
+func (w *Workspace) Name() string {
+	return w.info.Name
+}
+
+// Finish marks the test of the workspace as finished and removes the workspace according to the policy.
+// Only the first call has an effect.
+func (w *Workspace) Finish(succeeded bool) error {
+	var err error
+	w.once.Do(func() {
+		err = w.manager.finish(w, succeeded)
+	})
+	return err
+}
+
+// Manager creates one workspace per test below its root directory and removes them according to its policy.
+// It is safe for concurrent use.
+type Manager struct {
+	Root   string
+	Policy Policy
+
+	mutex  sync.Mutex
+	active map[string]bool
+}
+
+// NewManager creates a Manager for workspaces in the given root directory
+func NewManager(root string, policy Policy) *Manager {
+	return &Manager{
+		Root:   root,
+		Policy: policy,
+		active: map[string]bool{},
+	}
+}
+
+// Create creates the workspace of a test, it is named after the keptnContext. If the keptnContext already has a
+// workspace (e.g. the test of another stage has been kept), a number is appended.
+func (m *Manager) Create(keptnContext string, triggeredID string) (*Workspace, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if err := os.MkdirAll(m.Root, 0755); err != nil {
+		return nil, err
+	}
+
+	baseName := invalidNameCharacters.ReplaceAllString(keptnContext, "-")
+	if baseName == "" || baseName == "." || baseName == ".." {
+		baseName = "workspace"
+	}
+
+	name := baseName
+	for i := 2; ; i++ {
+		err := os.Mkdir(filepath.Join(m.Root, name), 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		name = fmt.Sprintf("%s-%d", baseName, i)
+	}
+
+	workspace := &Workspace{
+		Dir: filepath.Join(m.Root, name),
+		info: Info{
+			Name:         name,
+			KeptnContext: keptnContext,
+			TriggeredID:  triggeredID,
+			Created:      time.Now().UTC(),
+		},
+		manager: m,
+	}
+	if err := writeInfo(workspace.Dir, &workspace.info); err != nil {
+		os.RemoveAll(workspace.Dir)
+		return nil, err
+	}
+	m.active[name] = true
+	return workspace, nil
+}
+
+func (m *Manager) finish(workspace *Workspace, succeeded bool) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.active, workspace.info.Name)
+
+	if m.Policy.Cleanup == CleanupAlways || (m.Policy.Cleanup == CleanupOnSuccess && succeeded) {
+		return os.RemoveAll(workspace.Dir)
+	}
+
+	finished := time.Now().UTC()
+	workspace.info.Finished = &finished
+	workspace.info.Succeeded = succeeded
+	if err := writeInfo(workspace.Dir, &workspace.info); err != nil {
+		return err
+	}
+
+	// the workspace may exceed the number of workspaces to keep
+	_, err := m.clean()
+	return err
+}
+
+// List returns all workspaces, the most recently used first
+func (m *Manager) List() ([]*Info, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.list()
+}
+
+func (m *Manager) list() ([]*Info, error) {
+	entries, err := ioutil.ReadDir(m.Root)
+	if os.IsNotExist(err) {
+		return []*Info{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	infos := []*Info{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := readInfo(filepath.Join(m.Root, entry.Name()))
+		if err != nil {
+			// not created by the manager
+			continue
+		}
+		info.Name = entry.Name()
+		info.Active = m.active[entry.Name()]
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].lastUsed().After(infos[j].lastUsed())
+	})
+	return infos, nil
+}
+
+// Get returns the workspace with the given name including its files
+func (m *Manager) Get(name string) (*Info, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	dir, err := m.dir(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := readInfo(dir)
+	if err != nil {
+		return nil, ErrWorkspaceNotFound
+	}
+	info.Name = name
+	info.Active = m.active[name]
+
+	info.Files = []string{}
+	err = filepath.Walk(dir, func(path string, fileInfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fileInfo.IsDir() || fileInfo.Name() == MetadataFilename {
+			return nil
+		}
+		relative, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		info.Files = append(info.Files, relative)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Remove removes the workspace with the given name, unless its test is still running
+func (m *Manager) Remove(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	dir, err := m.dir(name)
+	if err != nil {
+		return err
+	}
+	if m.active[name] {
+		return ErrWorkspaceActive
+	}
+	return os.RemoveAll(dir)
+}
+
+// dir returns the directory of the workspace with the given name
+func (m *Manager) dir(name string) (string, error) {
+	if name == "" || invalidNameCharacters.MatchString(name) || name == "." || name == ".." {
+		return "", ErrWorkspaceNotFound
+	}
+	dir := filepath.Join(m.Root, name)
+	if _, err := os.Stat(filepath.Join(dir, MetadataFilename)); err != nil {
+		return "", ErrWorkspaceNotFound
+	}
+	return dir, nil
+}
+
+// Clean removes the kept workspaces exceeding KeepLast or MaxAge of the policy and returns their names.
+// Workspaces left over by a previous instance of the service are treated like kept workspaces.
+func (m *Manager) Clean() ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.clean()
+}
+
+func (m *Manager) clean() ([]string, error) {
+	infos, err := m.list()
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	kept := 0
+	for _, info := range infos {
+		if info.Active {
+			continue
+		}
+		tooMany := m.Policy.KeepLast > 0 && kept >= m.Policy.KeepLast
+		tooOld := m.Policy.MaxAge > 0 && time.Since(info.lastUsed()) > m.Policy.MaxAge
+		if !tooMany && !tooOld {
+			kept++
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(m.Root, info.Name)); err != nil {
+			return removed, fmt.Errorf("could not remove workspace %s: %w", info.Name, err)
+		}
+		removed = append(removed, info.Name)
+	}
+	return removed, nil
+}
+
+// RunJanitor cleans the workspaces right away and then in the given interval, until the context is done
+func (m *Manager) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		removed, err := m.Clean()
+		if err != nil {
+			log.Printf("Could not clean workspaces: %s", err.Error())
+		}
+		for _, name := range removed {
+			log.Printf("Removed workspace %s", name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func readInfo(dir string) (*Info, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, MetadataFilename))
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{}
+	if err := json.Unmarshal(content, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func writeInfo(dir string, info *Info) error {
+	content, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, MetadataFilename), content, 0644)
+}
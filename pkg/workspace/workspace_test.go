@@ -0,0 +1,148 @@
+package workspace
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createManager(t *testing.T, policy Policy) *Manager {
+	dir, err := ioutil.TempDir("", "workspace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return NewManager(filepath.Join(dir, "workspaces"), policy)
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	assert.NoError(t, Policy{Cleanup: CleanupAlways}.Validate())
+	assert.NoError(t, Policy{Cleanup: CleanupOnSuccess, KeepLast: 5, MaxAge: time.Hour}.Validate())
+	assert.Error(t, Policy{Cleanup: "sometimes"}.Validate())
+	assert.Error(t, Policy{Cleanup: CleanupNever, KeepLast: -1}.Validate())
+	assert.Error(t, Policy{Cleanup: CleanupNever, MaxAge: -time.Hour}.Validate())
+}
+
+func TestCreate(t *testing.T) {
+	manager := createManager(t, Policy{Cleanup: CleanupNever})
+
+	first, err := manager.Create("context-1", "triggered-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "context-1", first.Name())
+	assert.Equal(t, filepath.Join(manager.Root, "context-1"), first.Dir)
+
+	// another test of the same keptnContext gets its own workspace
+	second, err := manager.Create("context-1", "triggered-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "context-1-2", second.Name())
+
+	third, err := manager.Create("../context", "triggered-3")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(manager.Root, "..-context"), third.Dir)
+
+	infos, err := manager.List()
+	assert.NoError(t, err)
+	assert.Len(t, infos, 3)
+	for _, info := range infos {
+		assert.True(t, info.Active)
+	}
+}
+
+func TestFinish(t *testing.T) {
+	tests := []struct {
+		cleanup       string
+		succeeded     bool
+		expectRemoved bool
+	}{
+		{cleanup: CleanupAlways, succeeded: false, expectRemoved: true},
+		{cleanup: CleanupOnSuccess, succeeded: true, expectRemoved: true},
+		{cleanup: CleanupOnSuccess, succeeded: false, expectRemoved: false},
+		{cleanup: CleanupNever, succeeded: true, expectRemoved: false},
+	}
+
+	for _, test := range tests {
+		manager := createManager(t, Policy{Cleanup: test.cleanup})
+		workspace, err := manager.Create("context", "triggered")
+		assert.NoError(t, err)
+		assert.NoError(t, workspace.Finish(test.succeeded))
+
+		_, err = os.Stat(workspace.Dir)
+		assert.Equal(t, test.expectRemoved, os.IsNotExist(err), test.cleanup)
+		if !test.expectRemoved {
+			info, err := manager.Get("context")
+			assert.NoError(t, err)
+			assert.False(t, info.Active)
+			assert.NotNil(t, info.Finished)
+			assert.Equal(t, test.succeeded, info.Succeeded)
+		}
+	}
+}
+
+func TestFinish_KeepLast(t *testing.T) {
+	manager := createManager(t, Policy{Cleanup: CleanupNever, KeepLast: 2})
+
+	for _, keptnContext := range []string{"first", "second", "third"} {
+		workspace, err := manager.Create(keptnContext, "triggered")
+		assert.NoError(t, err)
+		assert.NoError(t, workspace.Finish(false))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	infos, err := manager.List()
+	assert.NoError(t, err)
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "third", infos[0].Name)
+	assert.Equal(t, "second", infos[1].Name)
+}
+
+func TestClean_MaxAge(t *testing.T) {
+	manager := createManager(t, Policy{Cleanup: CleanupNever, MaxAge: time.Hour})
+
+	old, err := manager.Create("old", "triggered")
+	assert.NoError(t, err)
+	assert.NoError(t, old.Finish(true))
+	// pretend the workspace has been finished two hours ago
+	finished := time.Now().Add(-2 * time.Hour).UTC()
+	old.info.Finished = &finished
+	assert.NoError(t, writeInfo(old.Dir, &old.info))
+
+	// active workspaces are never removed
+	active, err := manager.Create("active", "triggered")
+	assert.NoError(t, err)
+	active.info.Created = finished
+	assert.NoError(t, writeInfo(active.Dir, &active.info))
+
+	removed, err := manager.Clean()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"old"}, removed)
+
+	infos, err := manager.List()
+	assert.NoError(t, err)
+	assert.Len(t, infos, 1)
+	assert.Equal(t, "active", infos[0].Name)
+}
+
+func TestGetAndRemove(t *testing.T) {
+	manager := createManager(t, Policy{Cleanup: CleanupNever})
+
+	workspace, err := manager.Create("context", "triggered")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(workspace.Dir, "locustfile.py"), []byte{}, 0644))
+
+	info, err := manager.Get("context")
+	assert.NoError(t, err)
+	assert.Equal(t, "triggered", info.TriggeredID)
+	assert.Equal(t, []string{"locustfile.py"}, info.Files)
+
+	assert.Equal(t, ErrWorkspaceActive, manager.Remove("context"))
+	assert.NoError(t, workspace.Finish(false))
+	assert.NoError(t, manager.Remove("context"))
+
+	_, err = manager.Get("context")
+	assert.Equal(t, ErrWorkspaceNotFound, err)
+	assert.Equal(t, ErrWorkspaceNotFound, manager.Remove("../workspaces"))
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	cloudevents "github.com/cloudevents/sdk-go/v2"
+	"github.com/google/uuid"
+	api "github.com/keptn/go-utils/pkg/api/utils"
+	keptnv2 "github.com/keptn/go-utils/pkg/lib/v0_2_0"
+
+	"github.com/keptn-sandbox/locust-service/pkg/schedule"
+	"github.com/keptn-sandbox/locust-service/pkg/workerpool"
+)
+
+// scheduleEventSender takes the events of scheduled tests, which are not part of a Keptn sequence, and only logs them
+type scheduleEventSender struct {
+	id string
+}
+
+func (s *scheduleEventSender) SendEvent(event cloudevents.Event) error {
+	data := &keptnv2.EventData{}
+	if err := event.DataAs(data); err == nil && data.Message != "" {
+		log.Printf("[schedule %s] %s: %s", s.id, event.Type(), data.Message)
+	}
+	return nil
+}
+
+// scheduledService is a service whose schedule.yaml is loaded
+type scheduledService struct {
+	project string
+	stage   string
+	service string
+}
+
+// parseScheduledServices parses services given as project/stage/service
+func parseScheduledServices(values []string) ([]scheduledService, error) {
+	services := []scheduledService{}
+	for _, value := range values {
+		parts := strings.Split(strings.TrimSpace(value), "/")
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid service %s, must be project/stage/service", value)
+		}
+		services = append(services, scheduledService{project: parts[0], stage: parts[1], service: parts[2]})
+	}
+	return services, nil
+}
+
+// newScheduledKeptn creates a Keptn handler for a test.triggered event of the service that has not been sent by
+// Keptn, it has its own keptnContext and its events are only logged
+func newScheduledKeptn(project string, stage string, service string, host string, id string) (*keptnv2.Keptn, cloudevents.Event, *keptnv2.TestTriggeredEventData, error) {
+	data := &keptnv2.TestTriggeredEventData{
+		EventData: keptnv2.EventData{
+			Project: project,
+			Stage:   stage,
+			Service: service,
+		},
+	}
+	if host != "" {
+		data.Deployment.DeploymentURIsPublic = []string{host}
+	}
+
+	event := cloudevents.NewEvent()
+	event.SetID(uuid.New().String())
+	event.SetType(keptnv2.GetTriggeredEventType(keptnv2.TestTaskName))
+	event.SetSource(ServiceName)
+	event.SetTime(time.Now())
+	event.SetExtension("shkeptncontext", uuid.New().String())
+	if err := event.SetData(cloudevents.ApplicationJSON, data); err != nil {
+		return nil, event, nil, err
+	}
+
+	options := keptnOptions
+	options.EventSender = &scheduleEventSender{id: id}
+	myKeptn, err := keptnv2.NewKeptn(&event, options)
+	if err != nil {
+		return nil, event, nil, err
+	}
+	return myKeptn, event, data, nil
+}
+
+// runScheduledTest runs the test of the schedule through the scheduleQueue like a triggered test, so it can be
+// canceled and is recorded in the run history. It blocks until the test has finished.
+func runScheduledTest(entry *schedule.Entry) {
+	myKeptn, event, data, err := newScheduledKeptn(entry.Project, entry.Stage, entry.Service, entry.Schedule.Host, entry.ID())
+	if err != nil {
+		log.Printf("Could not run schedule %s: %s", entry.ID(), err.Error())
+		return
+	}
+
+	ctx, done := runRegistry.Register(context.Background(), event.ID(), myKeptn.KeptnContext)
+	recordQueuedTest(myKeptn, event, data, entry.ID())
+	finished := make(chan struct{})
+	_, err = scheduleQueue.Submit(func() {
+		defer close(finished)
+		defer done()
+		err := handleTest(workerpool.NewContext(ctx, scheduleQueue), locustRunner, myKeptn, event, data, entry)
+		if err != nil {
+			log.Printf("Failed to run schedule %s: %s", entry.ID(), err.Error())
+		}
+		finishQueuedRuns(ctx, myKeptn.KeptnContext, event.ID(), err)
+	})
+	if err != nil {
+		log.Printf("Skipping schedule %s, %s", entry.ID(), err.Error())
+		finishQueuedRuns(ctx, myKeptn.KeptnContext, event.ID(), err)
+		done()
+		return
+	}
+	<-finished
+}
+
+// loadSchedules reads the schedule.yaml of every service and passes its schedules to the scheduler. Services whose
+// schedule.yaml cannot be loaded or parsed keep their previous schedules, e.g. while the configuration service is
+// unavailable, only services without schedule.yaml have none.
+func loadSchedules(scheduler *schedule.Scheduler, services []scheduledService) {
+	for _, s := range services {
+		myKeptn, _, _, err := newScheduledKeptn(s.project, s.stage, s.service, "", "")
+		if err != nil {
+			log.Printf("Could not load %s of %s/%s/%s: %s", schedule.Filename, s.project, s.stage, s.service, err.Error())
+			continue
+		}
+
+		schedules := []*schedule.Schedule{}
+		content, err := myKeptn.GetKeptnResource(schedule.Filename)
+		if err != nil && !isResourceNotFound(err) {
+			log.Printf("Could not load %s of %s/%s/%s, keeping its schedules: %s", schedule.Filename, s.project, s.stage, s.service, err.Error())
+			continue
+		}
+		if err == nil {
+			config, err := schedule.Parse(content)
+			if err != nil {
+				log.Printf("Could not parse %s of %s/%s/%s: %s", schedule.Filename, s.project, s.stage, s.service, err.Error())
+				serviceMetrics.ConfigParseFailed()
+				continue
+			}
+			schedules = config.Schedules
+		}
+
+		if err := scheduler.Set(s.project, s.stage, s.service, schedules); err != nil {
+			log.Printf("Could not schedule %s/%s/%s: %s", s.project, s.stage, s.service, err.Error())
+		}
+	}
+}
+
+// isResourceNotFound returns true if GetKeptnResource failed because the resource does not exist. go-utils only passes
+// on the message of the error, which ends with the message of api.ResourceNotFoundError if the configuration service
+// answered with 404.
+func isResourceNotFound(err error) bool {
+	return os.IsNotExist(err) || strings.HasSuffix(err.Error(), api.ResourceNotFoundError.Error())
+}
+
+// startScheduler loads the schedules of the services in the given interval and runs them until the context is done
+func startScheduler(ctx context.Context, services []scheduledService, reloadInterval time.Duration) {
+	scheduler := schedule.New(runScheduledTest)
+	go scheduler.Start(ctx)
+
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		loadSchedules(scheduler, services)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}